@@ -0,0 +1,336 @@
+package dice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A Span is a range of byte offsets into a parsed string. Start is inclusive
+// and End is exclusive.
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Pos returns the Span. Nodes embed a Span to implement Node.
+func (s Span) Pos() Span {
+	return s
+}
+
+// A Node is an element of a parsed dice expression's abstract syntax tree.
+type Node interface {
+	// Pos returns the span of the input the Node was parsed from.
+	Pos() Span
+
+	// String returns a representation of the Node that can be re-parsed to
+	// yield an equivalent Node.
+	String() string
+}
+
+// An Operator is an arithmetic operator usable within dice expressions.
+type Operator int
+
+// Arithmetic and comparison operators.
+const (
+	OpUnknown Operator = iota
+	OpAdd              // +
+	OpSub              // -
+	OpMul              // *
+	OpDiv              // /
+	OpMod              // %
+	OpPow              // ^
+	OpLss              // <
+	OpLeq              // <=
+	OpGtr              // >
+	OpGeq              // >=
+	OpEql              // =
+	OpNeq              // !=
+)
+
+var operators = [...]string{
+	OpUnknown: "",
+	OpAdd:     "+",
+	OpSub:     "-",
+	OpMul:     "*",
+	OpDiv:     "/",
+	OpMod:     "%",
+	OpPow:     "^",
+	OpLss:     "<",
+	OpLeq:     "<=",
+	OpGtr:     ">",
+	OpGeq:     ">=",
+	OpEql:     "=",
+	OpNeq:     "!=",
+}
+
+func (o Operator) String() string {
+	s := ""
+	if 0 <= o && o < Operator(len(operators)) {
+		s = operators[o]
+	}
+	return s
+}
+
+// Eval applies the Operator to two operands.
+func (o Operator) Eval(left, right float64) (float64, error) {
+	switch o {
+	case OpAdd:
+		return left + right, nil
+	case OpSub:
+		return left - right, nil
+	case OpMul:
+		return left * right, nil
+	case OpDiv:
+		return left / right, nil
+	case OpMod:
+		return math.Mod(left, right), nil
+	case OpPow:
+		return math.Pow(left, right), nil
+	case OpLss:
+		return truth(left < right), nil
+	case OpLeq:
+		return truth(left <= right), nil
+	case OpGtr:
+		return truth(left > right), nil
+	case OpGeq:
+		return truth(left >= right), nil
+	case OpEql:
+		return truth(left == right), nil
+	case OpNeq:
+		return truth(left != right), nil
+	}
+	return 0, fmt.Errorf("unknown operator %q", o)
+}
+
+// IsComparison returns whether the Operator is a comparison, which evaluates
+// to 1 if the comparison holds and 0 otherwise.
+func (o Operator) IsComparison() bool {
+	return OpLss <= o && o <= OpNeq
+}
+
+// truth returns 1 if b is true, and 0 otherwise.
+func truth(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// MarshalJSON ensures the Operator is encoded as its string representation.
+func (o Operator) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.String())
+}
+
+// A NumberNode is a numeric literal.
+type NumberNode struct {
+	Span
+	Value float64 `json:"value"`
+}
+
+func (n *NumberNode) String() string {
+	return strconv.FormatFloat(n.Value, 'f', -1, 64)
+}
+
+// A NotationNode is a dice notation, such as 3d6 or 4d6dl1, that describes a
+// group of like dice and their modifiers. If Count is not nil, the number of
+// dice is the value of Count when evaluated, like the @level of "@level d6".
+type NotationNode struct {
+	Span
+	Notation   string           `json:"notation"`
+	Properties RollerProperties `json:"properties"`
+	Count      *VariableNode    `json:"count,omitempty"`
+}
+
+func (n *NotationNode) String() string {
+	if n.Count != nil {
+		return n.Count.String() + " " + n.Notation
+	}
+	return n.Notation
+}
+
+// Resolve returns a copy of the notation's properties with the count of dice
+// resolved from the context's parameters, if the notation has a Count.
+func (n *NotationNode) Resolve(ctx context.Context) (RollerProperties, error) {
+	props := n.Properties
+	if n.Count == nil {
+		return props, nil
+	}
+	count, err := n.Count.Value(ctx)
+	if err != nil {
+		return props, err
+	}
+	if count < 0 || count != math.Trunc(count) || count > math.MaxInt32 {
+		return props, fmt.Errorf("count %s of %s is %v, not a whole number of dice",
+			n.Count, n.Notation, count)
+	}
+	props.Count = int(count)
+	return props, nil
+}
+
+// A VariableNode is a reference to a named parameter, such as @str_mod, whose
+// value is bound by the context's parameters (CtxKeyParameters) when the
+// expression is evaluated.
+type VariableNode struct {
+	Span
+	Name string `json:"name"`
+}
+
+func (n *VariableNode) String() string {
+	return "@" + n.Name
+}
+
+// Value returns the value bound to the variable by the context's parameters.
+func (n *VariableNode) Value(ctx context.Context) (float64, error) {
+	return CtxParameter(ctx, n.Name)
+}
+
+// A UnaryNode is an operator applied to a single operand, such as -d4.
+type UnaryNode struct {
+	Span
+	Op Operator `json:"op"`
+	X  Node     `json:"x"`
+}
+
+func (n *UnaryNode) String() string {
+	return n.Op.String() + n.X.String()
+}
+
+// A BinaryNode is an operator applied to two operands, such as d20+5.
+type BinaryNode struct {
+	Span
+	Op    Operator `json:"op"`
+	Left  Node     `json:"left"`
+	Right Node     `json:"right"`
+}
+
+func (n *BinaryNode) String() string {
+	if n.Op.IsComparison() {
+		// a comparison directly following a notation would be read as one
+		// of the notation's modifiers
+		return n.Left.String() + " " + n.Op.String() + " " + n.Right.String()
+	}
+	return n.Left.String() + n.Op.String() + n.Right.String()
+}
+
+// A LabelNode is an operand annotated with a bracketed label, such as
+// 1d8[slashing] or (2d6+3)[fire].
+type LabelNode struct {
+	Span
+	X     Node   `json:"x"`
+	Label string `json:"label"`
+}
+
+func (n *LabelNode) String() string {
+	return n.X.String() + "[" + n.Label + "]"
+}
+
+// A ConditionalNode is a conditional expression, such as d20 >= 15 ? 2d6 : 0,
+// whose value is Then if Cond is non-zero, and Else otherwise.
+type ConditionalNode struct {
+	Span
+	Cond Node `json:"cond"`
+	Then Node `json:"then"`
+	Else Node `json:"else"`
+}
+
+func (n *ConditionalNode) String() string {
+	return n.Cond.String() + " ? " + n.Then.String() + " : " + n.Else.String()
+}
+
+// A ParenNode is a parenthesized sub-expression.
+type ParenNode struct {
+	Span
+	X Node `json:"x"`
+}
+
+func (n *ParenNode) String() string {
+	return "(" + n.X.String() + ")"
+}
+
+// A CallNode is a function call, such as max(d20,d20).
+type CallNode struct {
+	Span
+	Func string `json:"func"`
+	Args []Node `json:"args"`
+}
+
+func (n *CallNode) String() string {
+	args := make([]string, len(n.Args))
+	for i, arg := range n.Args {
+		args[i] = arg.String()
+	}
+	return n.Func + "(" + strings.Join(args, ",") + ")"
+}
+
+// A ListNode is a braced list of expressions, such as {d20+5, d20+5}. A list
+// without modifiers that is an entire expression is a list of separate rolls;
+// otherwise its value is the sum of its items.
+//
+// The Modifiers of a grouped list, such as {1d20+5, 1d12+3}kh1, drop, keep, or
+// sort the list's items by their totals, as they would the dice of a group.
+type ListNode struct {
+	Span
+	Items     []Node       `json:"items"`
+	Modifiers ModifierList `json:"modifiers,omitempty"`
+}
+
+func (n *ListNode) String() string {
+	items := make([]string, len(n.Items))
+	for i, item := range n.Items {
+		items[i] = item.String()
+	}
+	return "{" + strings.Join(items, ",") + "}" + n.Modifiers.String()
+}
+
+// A RepeatNode is an expression to be rolled a number of times as separate
+// rolls, such as 6x 4d6kh3.
+type RepeatNode struct {
+	Span
+	Count int  `json:"count"`
+	X     Node `json:"x"`
+}
+
+func (n *RepeatNode) String() string {
+	return strconv.Itoa(n.Count) + "x " + n.X.String()
+}
+
+// Inspect traverses an AST in depth-first order, calling f for each Node. If f
+// returns false, the Node's children are not visited.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+	switch n := node.(type) {
+	case *UnaryNode:
+		Inspect(n.X, f)
+	case *BinaryNode:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *ParenNode:
+		Inspect(n.X, f)
+	case *LabelNode:
+		Inspect(n.X, f)
+	case *ConditionalNode:
+		Inspect(n.Cond, f)
+		Inspect(n.Then, f)
+		Inspect(n.Else, f)
+	case *NotationNode:
+		if n.Count != nil {
+			Inspect(n.Count, f)
+		}
+	case *CallNode:
+		for _, arg := range n.Args {
+			Inspect(arg, f)
+		}
+	case *ListNode:
+		for _, item := range n.Items {
+			Inspect(item, f)
+		}
+	case *RepeatNode:
+		Inspect(n.X, f)
+	}
+}
@@ -0,0 +1,130 @@
+package dice
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A RollEvent records a single draw of a die's value. An event is recorded each
+// time a die is rolled, including rerolls whose values are later discarded.
+type RollEvent struct {
+	// Die is the die that was rolled.
+	Die *Die `json:"-"`
+
+	// Path is the position of the die within its groups as dot-separated
+	// indices, outermost first: the third die of a group has the path "2".
+	Path string `json:"path"`
+
+	Type    DieType `json:"type,omitempty"`
+	Size    int     `json:"size"`
+	Value   float64 `json:"value"`
+	Rerolls int     `json:"rerolls"`
+
+	// Modifier is the modifier that caused the die to be rolled, or nil if
+	// the die was rolled initially.
+	Modifier Modifier `json:"modifier,omitempty"`
+
+	// Nonce is the nonce of the roll, if the die was rolled with a FairSource.
+	Nonce *uint64 `json:"nonce,omitempty"`
+}
+
+// String returns a short description of the event.
+func (e *RollEvent) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "die %s (%s) rolled %s", e.Path, (&Die{Type: e.Type, Size: e.Size}).String(),
+		strconv.FormatFloat(e.Value, 'f', -1, 64))
+	if e.Rerolls > 0 {
+		fmt.Fprintf(&b, " on reroll %d", e.Rerolls)
+	}
+	if e.Modifier != nil {
+		fmt.Fprintf(&b, " by %s", e.Modifier)
+	}
+	return b.String()
+}
+
+// An Auditor records RollEvents. An Auditor set within a context with
+// CtxKeyAuditor is sent an event for every die rolled with the context, and must
+// be safe for concurrent use if the context is shared between goroutines.
+type Auditor interface {
+	Record(context.Context, *RollEvent)
+}
+
+// The AuditorFunc type is an adapter to allow the use of ordinary functions as
+// Auditors.
+type AuditorFunc func(context.Context, *RollEvent)
+
+// Record calls f(ctx, e).
+func (f AuditorFunc) Record(ctx context.Context, e *RollEvent) {
+	f(ctx, e)
+}
+
+// An AuditLog is an Auditor that keeps every event it records in order. It is
+// safe for concurrent use.
+type AuditLog struct {
+	mu     sync.Mutex
+	events []*RollEvent
+}
+
+// Record appends an event to the log.
+func (l *AuditLog) Record(_ context.Context, e *RollEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, e)
+}
+
+// Events returns the events recorded by the log.
+func (l *AuditLog) Events() []*RollEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]*RollEvent(nil), l.events...)
+}
+
+// audit records a roll of a die with the context's Auditor, if it has one.
+func audit(ctx context.Context, d *Die) {
+	auditor := CtxAuditor(ctx)
+	if auditor == nil || d.Result == nil {
+		return
+	}
+	modifier, _ := ctx.Value(ctxKeyModifier).(Modifier)
+	if modifier == nil && d.Rerolls == 0 {
+		// the die's initial roll is caused by the modifier that added it
+		modifier = d.cause
+	}
+	auditor.Record(ctx, &RollEvent{
+		Die:      d,
+		Path:     rollerPath(d),
+		Type:     d.Type,
+		Size:     d.Size,
+		Value:    d.Result.Value,
+		Rerolls:  d.Rerolls,
+		Modifier: modifier,
+		Nonce:    d.Result.Nonce,
+	})
+}
+
+// withModifier returns a context recording that rolls made with it were caused
+// by a modifier, for auditing and die history.
+func withModifier(ctx context.Context, m Modifier) context.Context {
+	return context.WithValue(ctx, ctxKeyModifier, m)
+}
+
+// rollerPath returns the position of a Roller within its ancestor groups.
+func rollerPath(r Roller) string {
+	var path []string
+	for parent := r.Parent(); parent != nil; r, parent = parent, parent.Parent() {
+		group, ok := parent.(*RollerGroup)
+		if !ok {
+			break
+		}
+		for i, child := range group.Group {
+			if child == r {
+				path = append([]string{strconv.Itoa(i)}, path...)
+				break
+			}
+		}
+	}
+	return strings.Join(path, ".")
+}
@@ -0,0 +1,52 @@
+package dice
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	log := new(AuditLog)
+	ctx := context.WithValue(context.Background(), CtxKeySource, NewSeededSource(7))
+	ctx = context.WithValue(ctx, CtxKeyAuditor, log)
+
+	props, err := ParseNotation(ctx, "20d4r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := MustNewRollerGroup(&props)
+	if err := group.FullRoll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var rerolls int
+	last := make(map[string]*RollEvent)
+	for _, e := range log.Events() {
+		if e.Rerolls > 0 {
+			if e.Modifier == nil || e.Modifier.String() != "r1" {
+				t.Errorf("reroll event %s: modifier = %v, want r1", e, e.Modifier)
+			}
+		} else if e.Modifier != nil {
+			t.Errorf("initial roll event %s: modifier = %v, want nil", e, e.Modifier)
+		}
+		last[e.Path] = e
+	}
+	for i, r := range group.Group {
+		die := r.(*Die)
+		rerolls += die.Rerolls
+		e, ok := last[rollerPath(die)]
+		if !ok {
+			t.Errorf("die %d: no events recorded", i)
+			continue
+		}
+		if e.Die != die || e.Value != die.Result.Value || e.Rerolls != die.Rerolls {
+			t.Errorf("die %d: last event %s does not match die %+v", i, e, die.Result)
+		}
+	}
+	if rerolls == 0 {
+		t.Fatal("no dice were rerolled; choose another seed")
+	}
+	if got, want := len(log.Events()), len(group.Group)+rerolls; got != want {
+		t.Errorf("recorded %d events, want %d", got, want)
+	}
+}
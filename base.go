@@ -9,6 +9,7 @@ import (
 	"math/rand"
 	"regexp"
 	"strings"
+	"sync/atomic"
 )
 
 // MaxRolls is the maximum number of rolls allowed for a request.
@@ -20,6 +21,9 @@ var MaxRolls uint64 = math.MaxUint64
 // Source must be safe for concurrent use: to use something akin to math/rand's
 // thread safe global reader try binding a Source64 with a Mutex. See
 // math/rand's globalRand variable source code for an example.
+//
+// A different source can be used for a set of rolls by setting it within their
+// context using CtxKeySource.
 var Source *rand.Rand
 
 func init() {
@@ -48,6 +52,38 @@ func (s *csprngSource) Uint64() (u uint64) {
 	return
 }
 
+// NewSeededSource returns a deterministic RNG source for the given seed. The
+// same seed always yields the same sequence of values, so rolls made using the
+// source can be replayed and verified by a third party.
+//
+// The source is a SplitMix64 generator. It is safe for concurrent use, but
+// rolls made concurrently will not be reproducible.
+func NewSeededSource(seed int64) *rand.Rand {
+	return rand.New(&seededSource{state: uint64(seed)})
+}
+
+// seededSource is a SplitMix64 generator that implements rand.Source64.
+type seededSource struct {
+	state uint64
+}
+
+// Seed resets the source's state to that of a new source for seed.
+func (s *seededSource) Seed(seed int64) {
+	atomic.StoreUint64(&s.state, uint64(seed))
+}
+
+func (s *seededSource) Int63() int64 {
+	return int64(s.Uint64() & ^uint64(1<<63))
+}
+
+// Uint64 satisfies the rand.Source64 interface.
+func (s *seededSource) Uint64() uint64 {
+	z := atomic.AddUint64(&s.state, 0x9e3779b97f4a7c15)
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
 // CryptoInt64 is a convenience function that returns a cryptographically random
 // int64 using the system's CSPRNG. If there is a problem generating enough
 // entropy it will return a non-nil error.
@@ -10,6 +10,21 @@ import (
 // sources use half the entropy of a regular rand.Source.
 var _ = (rand.Source64)(&csprngSource{})
 
+var _ = (rand.Source64)(&seededSource{})
+
+func TestNewSeededSource(t *testing.T) {
+	// the first SplitMix64 output for a seed of 0
+	if got := NewSeededSource(0).Uint64(); got != 0xe220a8397b1dcdaf {
+		t.Errorf("NewSeededSource(0).Uint64() = %#x, want %#x", got, uint64(0xe220a8397b1dcdaf))
+	}
+	a, b := NewSeededSource(42), NewSeededSource(42)
+	for i := 0; i < 100; i++ {
+		if x, y := a.Intn(20), b.Intn(20); x != y {
+			t.Fatalf("roll %d differs between equally seeded sources: %d != %d", i, x, y)
+		}
+	}
+}
+
 // Set of basic range sizes.
 var benchmarks = []struct {
 	size int
@@ -1,9 +1,14 @@
 package command
 
 import (
+	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"os"
 	"strings"
 
+	"github.com/travis-g/dice"
 	"github.com/urfave/cli"
 )
 
@@ -50,3 +55,74 @@ func OutputField(c *cli.Context, i interface{}, field string) (string, error) {
 	}
 	return Output(c, data[field])
 }
+
+// NewContext returns a new dice context for a command. If a seed was provided
+// the context's rolls are made with a deterministic source for that seed. If a
+// client seed was provided the context's rolls are made with a provably fair
+// source, using the provided server seed or generating one. If auditing was
+// requested every roll is printed to stderr.
+func NewContext(c *cli.Context) (context.Context, error) {
+	ctx := dice.NewContextFromContext(context.Background())
+	switch {
+	case c.IsSet("client-seed"):
+		var (
+			fair *dice.FairSource
+			err  error
+		)
+		if c.IsSet("server-seed") {
+			seed, err := hex.DecodeString(c.String("server-seed"))
+			if err != nil {
+				return nil, fmt.Errorf("decoding server seed: %w", err)
+			}
+			fair = dice.NewFairSource(seed, c.String("client-seed"))
+		} else if fair, err = dice.GenerateFairSource(c.String("client-seed")); err != nil {
+			return nil, err
+		}
+		ctx = context.WithValue(ctx, dice.CtxKeySource, fair)
+	case c.IsSet("server-seed"):
+		return nil, errors.New("a client seed is required to roll with a server seed")
+	case c.IsSet("seed"):
+		ctx = context.WithValue(ctx, dice.CtxKeySource, dice.NewSeededSource(c.Int64("seed")))
+	}
+	if c.Bool("audit") {
+		ctx = context.WithValue(ctx, dice.CtxKeyAuditor, dice.AuditorFunc(printRollEvent))
+	}
+	return ctx, nil
+}
+
+// printRollEvent prints an audited roll to stderr.
+func printRollEvent(_ context.Context, e *dice.RollEvent) {
+	fmt.Fprintf(os.Stderr, "audit: %s\n", e)
+}
+
+// RevealFair prints the seeds of the context's provably fair source to stderr
+// so that its rolls can be verified, if the context has one.
+func RevealFair(ctx context.Context) {
+	fair := dice.CtxFairSource(ctx)
+	if fair == nil {
+		return
+	}
+	reveal := fair.Reveal()
+	fmt.Fprintf(os.Stderr, "commitment:  %s\nserver seed: %s\nclient seed: %s\n",
+		reveal.Commitment, reveal.ServerSeed, reveal.ClientSeed)
+}
+
+// FormatError renders an error for display. Parse errors are rendered along
+// with a caret-underlined snippet of the input and any suggested fix.
+func FormatError(err error) string {
+	var perr *dice.ErrParseError
+	if !errors.As(err, &perr) || perr.Caret() == "" {
+		return err.Error()
+	}
+	var b strings.Builder
+	b.WriteString(err.Error())
+	for _, line := range strings.Split(perr.Caret(), "\n") {
+		b.WriteString("\n  ")
+		b.WriteString(line)
+	}
+	if perr.Suggestion != "" {
+		b.WriteString("\nhint: ")
+		b.WriteString(perr.Suggestion)
+	}
+	return b.String()
+}
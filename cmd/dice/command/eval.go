@@ -1,10 +1,8 @@
 package command
 
 import (
-	"context"
 	"fmt"
 
-	"github.com/travis-g/dice"
 	"github.com/travis-g/dice/math"
 	"github.com/urfave/cli"
 )
@@ -13,17 +11,21 @@ import (
 // math.DiceExpression and print the result or return any errors during
 // evaluation.
 func EvalCommand(c *cli.Context) error {
-	ctx := dice.NewContextFromContext(context.Background())
+	ctx, err := NewContext(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
 
 	eval := c.Args().Get(0)
 	exp, err := math.EvaluateExpression(ctx, eval)
 	if err != nil {
-		return err
+		return cli.NewExitError(FormatError(err), 1)
 	}
 	out, err := Output(c, exp)
 	if err != nil {
 		return err
 	}
 	fmt.Println(out)
+	RevealFair(ctx)
 	return nil
 }
@@ -22,10 +22,23 @@ func REPLCommand(c *cli.Context) error {
 	in, _ := os.Stdin.Stat()
 	interactive := ((in.Mode() & os.ModeCharDevice) != 0)
 
+	// a seeded source is shared by the whole session, so that a session can
+	// be replayed by entering the same lines with the same seed
+	session, err := NewContext(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	// a fair session commits to its server seed up front and reveals it when
+	// the session ends
+	if fair := dice.CtxFairSource(session); fair != nil {
+		fmt.Fprintf(os.Stderr, "commitment: %s\n", fair.Commitment())
+		defer RevealFair(session)
+	}
+
 	// Begin the REPL:
 	for {
 		// context for each interation
-		ctx := dice.NewContextFromContext(context.Background())
+		ctx := context.WithValue(session, dice.CtxKeyTotalRolls, new(uint64))
 		if interactive {
 			fmt.Fprint(os.Stderr, replPrompt)
 		}
@@ -39,11 +52,11 @@ func REPLCommand(c *cli.Context) error {
 			ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 			exp, err := math.EvaluateExpression(ctx, line)
 			cancel()
-			if exp == nil {
+			if err == nil && exp == nil {
 				err = math.ErrNilExpression
 			}
 			if err != nil {
-				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, FormatError(err))
 				continue
 			}
 			out, err := Output(c, exp)
@@ -1,7 +1,6 @@
 package command
 
 import (
-	"context"
 	"fmt"
 
 	"github.com/travis-g/dice"
@@ -11,12 +10,15 @@ import (
 // RollCommand is a command that will create a Dice from the first argument
 // passed and roll it, printing the result.
 func RollCommand(c *cli.Context) error {
-	ctx := dice.NewContextFromContext(context.Background())
+	ctx, err := NewContext(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
 
 	roll := c.Args().Get(0)
 	props, err := dice.ParseNotation(ctx, roll)
 	if err != nil {
-		return err
+		return cli.NewExitError(FormatError(err), 1)
 	}
 	group, _ := dice.NewRollerGroup(&props)
 	err = group.FullRoll(ctx)
@@ -28,5 +30,6 @@ func RollCommand(c *cli.Context) error {
 		return err
 	}
 	fmt.Println(out)
+	RevealFair(ctx)
 	return nil
 }
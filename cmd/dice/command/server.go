@@ -30,7 +30,7 @@ func rollHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	props, err := dice.ParseNotation(ctx, vars["roll"])
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, FormatError(err), http.StatusBadRequest)
 		return
 	}
 	group, err := dice.NewRollerGroup(&props)
@@ -69,7 +69,7 @@ func rollPostHandler(w http.ResponseWriter, r *http.Request) {
 
 	props, err := dice.ParseNotation(ctx, vars["roll"].(string))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, FormatError(err), http.StatusBadRequest)
 		return
 	}
 	group, err := dice.NewRollerGroup(&props)
@@ -0,0 +1,49 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/travis-g/dice"
+	"github.com/travis-g/dice/probability"
+	"github.com/urfave/cli"
+)
+
+// Simulation wraps a probability.Simulation to render it for output.
+type Simulation struct {
+	*probability.Simulation
+}
+
+// String renders the simulation's estimates along with a text histogram of
+// the empirical distribution.
+func (s *Simulation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d trials, mean %s ± %s (95%% CI %s..%s), stddev %s, range %s..%s\n",
+		s.Expression, s.Trials, format(s.Mean), format(s.Interval[1]-s.Mean),
+		format(s.Interval[0]), format(s.Interval[1]), format(s.StdDev),
+		format(s.Distribution.Min()), format(s.Distribution.Max()))
+	b.WriteString(histogram(s.Distribution))
+	return b.String()
+}
+
+// SimulateCommand will evaluate the first argument it is provided as an
+// expression many times and print a summary of the results.
+func SimulateCommand(c *cli.Context) error {
+	ctx := dice.NewContextFromContext(context.Background())
+
+	s := &probability.Simulator{
+		Trials:  c.Int("trials"),
+		Workers: c.Int("workers"),
+	}
+	sim, err := s.Run(ctx, c.Args().Get(0))
+	if err != nil {
+		return cli.NewExitError(FormatError(err), 1)
+	}
+	out, err := Output(c, &Simulation{sim})
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
+	return nil
+}
@@ -0,0 +1,125 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/travis-g/dice"
+	"github.com/travis-g/dice/probability"
+	"github.com/urfave/cli"
+)
+
+// histogramWidth is the width of the longest bar of a printed histogram.
+const histogramWidth = 40
+
+// Stats is a summary of the exact outcome distribution of an expression.
+type Stats struct {
+	Expression   string                    `json:"expression"`
+	Mean         float64                   `json:"mean"`
+	StdDev       float64                   `json:"stddev"`
+	Min          float64                   `json:"min"`
+	Max          float64                   `json:"max"`
+	Mode         float64                   `json:"mode"`
+	Median       float64                   `json:"median"`
+	AtLeast      *probability.Point        `json:"at_least,omitempty"`
+	AtMost       *probability.Point        `json:"at_most,omitempty"`
+	Distribution *probability.Distribution `json:"distribution"`
+}
+
+// String renders the summary along with a text histogram of the distribution.
+func (s *Stats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: mean %s, stddev %s, range %s..%s\n",
+		s.Expression, format(s.Mean), format(s.StdDev), format(s.Min), format(s.Max))
+	if s.AtLeast != nil {
+		fmt.Fprintf(&b, "P(>= %s) = %s\n", format(s.AtLeast.Value), percent(s.AtLeast.Probability))
+	}
+	if s.AtMost != nil {
+		fmt.Fprintf(&b, "P(<= %s) = %s\n", format(s.AtMost.Value), percent(s.AtMost.Probability))
+	}
+
+	b.WriteString(histogram(s.Distribution))
+	return b.String()
+}
+
+// histogram renders a text histogram of a distribution, with a row per
+// outcome.
+func histogram(d *probability.Distribution) string {
+	outcomes := d.Outcomes()
+	var (
+		best  float64
+		width int
+	)
+	for _, o := range outcomes {
+		if o.Probability > best {
+			best = o.Probability
+		}
+		if w := len(format(o.Value)); w > width {
+			width = w
+		}
+	}
+	rows := make([]string, len(outcomes))
+	for i, o := range outcomes {
+		bar := int(o.Probability / best * histogramWidth)
+		row := fmt.Sprintf("%*s %8s %s", width, format(o.Value), percent(o.Probability), strings.Repeat("#", bar))
+		rows[i] = strings.TrimRight(row, " ")
+	}
+	return strings.Join(rows, "\n")
+}
+
+// format formats a float with the fewest digits necessary, to at most 4
+// decimal places.
+func format(f float64) string {
+	return strconv.FormatFloat(math.Round(f*1e4)/1e4, 'f', -1, 64)
+}
+
+// percent formats a probability as a percentage.
+func percent(p float64) string {
+	return strconv.FormatFloat(p*100, 'f', 2, 64) + "%"
+}
+
+// NewStats computes the summary of the distribution of an expression.
+func NewStats(ctx context.Context, expression string) (*Stats, error) {
+	d, err := probability.Calculate(ctx, expression)
+	if err != nil {
+		return nil, err
+	}
+	return &Stats{
+		Expression:   expression,
+		Mean:         d.Mean(),
+		StdDev:       d.StdDev(),
+		Min:          d.Min(),
+		Max:          d.Max(),
+		Mode:         d.Mode(),
+		Median:       d.Percentile(50),
+		Distribution: d,
+	}, nil
+}
+
+// StatsCommand will compute the exact distribution of the first argument it
+// is provided as an expression, and print a summary and histogram of it.
+func StatsCommand(c *cli.Context) error {
+	ctx := dice.NewContextFromContext(context.Background())
+
+	stats, err := NewStats(ctx, c.Args().Get(0))
+	if err != nil {
+		return cli.NewExitError(FormatError(err), 1)
+	}
+	if c.IsSet("at-least") {
+		n := c.Float64("at-least")
+		stats.AtLeast = &probability.Point{Value: n, Probability: stats.Distribution.AtLeast(n)}
+	}
+	if c.IsSet("at-most") {
+		n := c.Float64("at-most")
+		stats.AtMost = &probability.Point{Value: n, Probability: stats.Distribution.AtMost(n)}
+	}
+	out, err := Output(c, stats)
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
+	return nil
+}
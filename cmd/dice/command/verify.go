@@ -0,0 +1,102 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/travis-g/dice"
+	"github.com/travis-g/dice/math"
+	"github.com/urfave/cli"
+)
+
+// fairResult is the subset of an ExpressionResult's JSON encoding needed to
+// verify its rolls.
+type fairResult struct {
+	Original string  `json:"original"`
+	Result   float64 `json:"result"`
+	Dice     []struct {
+		Group []struct {
+			Type    dice.DieType `json:"type"`
+			Size    int          `json:"size"`
+			Faces   []dice.Face  `json:"faces"`
+			Weights []float64    `json:"weights"`
+			Result  *dice.Result `json:"result"`
+		} `json:"group"`
+	} `json:"dice"`
+}
+
+// expressionResult rebuilds the dice of the decoded result.
+func (r *fairResult) expressionResult() *math.ExpressionResult {
+	de := &math.ExpressionResult{Original: r.Original, Result: r.Result}
+	for _, g := range r.Dice {
+		group := &dice.RollerGroup{}
+		for _, d := range g.Group {
+			group.Group = append(group.Group, &dice.Die{
+				Type:    d.Type,
+				Size:    d.Size,
+				Faces:   d.Faces,
+				Weights: d.Weights,
+				Result:  d.Result,
+			})
+		}
+		de.Dice = append(de.Dice, group)
+	}
+	return de
+}
+
+// VerifyCommand verifies JSON-encoded expression results rolled with a
+// provably fair source against the source's revealed seeds. The results are
+// read from the file named by the first argument, or stdin if none is
+// provided, and must be every result rolled with the source, in order, like the
+// output of a fair REPL session.
+func VerifyCommand(c *cli.Context) error {
+	reveal := &dice.FairReveal{
+		Commitment: c.String("commitment"),
+		ServerSeed: c.String("server-seed"),
+		ClientSeed: c.String("client-seed"),
+	}
+	if reveal.Commitment == "" || reveal.ServerSeed == "" || !c.IsSet("client-seed") {
+		return cli.NewExitError("a commitment, server seed, and client seed are required", 1)
+	}
+
+	var in io.Reader = os.Stdin
+	if name := c.Args().Get(0); name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		defer f.Close()
+		in = f
+	}
+	var results []*math.ExpressionResult
+	dec := json.NewDecoder(in)
+	for {
+		var result fairResult
+		if err := dec.Decode(&result); err == io.EOF {
+			break
+		} else if err != nil {
+			return cli.NewExitError(fmt.Sprintf("decoding result %d: %s", len(results), err), 1)
+		}
+		results = append(results, result.expressionResult())
+	}
+	if len(results) == 0 {
+		return cli.NewExitError("no results to verify", 1)
+	}
+
+	n, err := math.VerifyResults(context.Background(), reveal, results...)
+	if errors.Is(err, dice.ErrCommitmentMismatch) {
+		return cli.NewExitError(err.Error(), 1)
+	} else if err != nil {
+		return cli.NewExitError(fmt.Sprintf("verified %d dice before failure: %s", n, err), 1)
+	}
+	if len(results) == 1 {
+		fmt.Printf("%s: verified %d dice\n", results[0].Original, n)
+		return nil
+	}
+	fmt.Printf("verified %d dice of %d results\n", n, len(results))
+	return nil
+}
@@ -6,10 +6,12 @@ package main
 import (
 	"fmt"
 	"os"
+	"runtime"
 	"sort"
 
 	"github.com/travis-g/dice"
 	"github.com/travis-g/dice/cmd/dice/command"
+	"github.com/travis-g/dice/probability"
 	"github.com/urfave/cli"
 )
 
@@ -46,6 +48,41 @@ func main() {
 		// },
 	}
 
+	// rollFlags are used by commands that roll dice directly
+	rollFlags := append([]cli.Flag{
+		&cli.Int64Flag{
+			Name:  "seed",
+			Usage: "roll deterministically using seed `N`",
+		},
+		&cli.StringFlag{
+			Name:  "client-seed",
+			Usage: "roll provably fairly using client seed `SEED`",
+		},
+		&cli.StringFlag{
+			Name:  "server-seed",
+			Usage: "hex-encoded server seed `HEX` for provably fair rolls (default: random)",
+		},
+		&cli.BoolFlag{
+			Name:  "audit",
+			Usage: "print every die rolled, including discarded rerolls, to stderr",
+		},
+	}, globalFlags...)
+
+	verifyFlags := []cli.Flag{
+		&cli.StringFlag{
+			Name:  "commitment",
+			Usage: "hex-encoded commitment `HEX` published before rolling",
+		},
+		&cli.StringFlag{
+			Name:  "server-seed",
+			Usage: "revealed hex-encoded server seed `HEX`",
+		},
+		&cli.StringFlag{
+			Name:  "client-seed",
+			Usage: "client seed `SEED` used to roll",
+		},
+	}
+
 	httpFlags := []cli.Flag{
 		&cli.StringFlag{
 			Name:   "http",
@@ -55,12 +92,36 @@ func main() {
 		},
 	}
 
+	statsFlags := append([]cli.Flag{
+		&cli.Float64Flag{
+			Name:  "at-least",
+			Usage: "print the probability of a result of at least `N`",
+		},
+		&cli.Float64Flag{
+			Name:  "at-most",
+			Usage: "print the probability of a result of at most `N`",
+		},
+	}, globalFlags...)
+
+	simulateFlags := append([]cli.Flag{
+		&cli.IntFlag{
+			Name:  "trials",
+			Value: probability.DefaultTrials,
+			Usage: "number of times to evaluate the expression",
+		},
+		&cli.IntFlag{
+			Name:  "workers",
+			Value: runtime.NumCPU(),
+			Usage: "number of parallel workers",
+		},
+	}, globalFlags...)
+
 	cmd.Commands = []cli.Command{
 		{
 			Name:    "eval",
 			Aliases: []string{"e"},
 			Usage:   "evaluate a dice expression",
-			Flags:   globalFlags,
+			Flags:   rollFlags,
 			Action: func(c *cli.Context) error {
 				return command.EvalCommand(c)
 			},
@@ -68,7 +129,7 @@ func main() {
 		{
 			Name:  "repl",
 			Usage: "enter a REPL mode",
-			Flags: globalFlags,
+			Flags: rollFlags,
 			Action: func(c *cli.Context) error {
 				return command.REPLCommand(c)
 			},
@@ -77,11 +138,36 @@ func main() {
 			Name:    "roll",
 			Aliases: []string{"r"},
 			Usage:   "roll plain dice groups",
-			Flags:   globalFlags,
+			Flags:   rollFlags,
 			Action: func(c *cli.Context) error {
 				return command.RollCommand(c)
 			},
 		},
+		{
+			Name:  "simulate",
+			Usage: "estimate the distribution of a dice expression by rolling it repeatedly",
+			Flags: simulateFlags,
+			Action: func(c *cli.Context) error {
+				return command.SimulateCommand(c)
+			},
+		},
+		{
+			Name:  "stats",
+			Usage: "print the probability distribution of a dice expression",
+			Flags: statsFlags,
+			Action: func(c *cli.Context) error {
+				return command.StatsCommand(c)
+			},
+		},
+		{
+			Name:      "verify",
+			Usage:     "verify the provably fair JSON roll results of a session, in order, against revealed seeds",
+			ArgsUsage: "[file]",
+			Flags:     verifyFlags,
+			Action: func(c *cli.Context) error {
+				return command.VerifyCommand(c)
+			},
+		},
 		{
 			Name:    "server",
 			Aliases: []string{"s"},
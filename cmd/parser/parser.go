@@ -1,238 +1,33 @@
-//go:build ignore
-// +build ignore
-
+/*
+Command parser parses a dice expression and prints its abstract syntax tree.
+*/
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"log"
-	"math"
 	"os"
-	"strconv"
 	"strings"
 
-	"github.com/alecthomas/kong"
-
-	"github.com/alecthomas/participle/v2"
-	"github.com/alecthomas/participle/v2/lexer/stateful"
-)
-
-var cli struct {
-	AST        bool               `help:"Print AST for expression."`
-	Set        map[string]float64 `short:"s" help:"Set variables."`
-	Expression []string           `arg required help:"Expression to evaluate."`
-}
-
-type Operator int
-
-const (
-	OpMul Operator = iota
-	OpDiv
-	OpAdd
-	OpSub
-)
-
-var operatorMap = map[string]Operator{"+": OpAdd, "-": OpSub, "*": OpMul, "/": OpDiv}
-
-func (o *Operator) Capture(s []string) error {
-	*o = operatorMap[s[0]]
-	return nil
-}
-
-// E --> T {( "+" | "-" ) T}
-// T --> F {( "*" | "/" ) F}
-// F --> P ["^" F]
-// P --> v | "(" E ")" | "-" T
-
-type Value struct {
-	Dice          *Notation   `  @@`
-	Number        *float64    `| @(Float|Int)`
-	Subexpression *Expression `| "(" @@ ")"`
-	// Variable      *string     `| @Ident`
-}
-
-type Notation struct {
-	Count *int64 `(@Int)? `
-	Size  *int64 `"d" @Int`
-}
-
-func (n *Notation) Capture(s []string) error {
-	log.Println(s)
-	size, err := strconv.ParseInt((s[0][1:]), 10, 64)
-	*n = Notation{
-		Size: &size,
-	}
-	return err
-}
-
-type Factor struct {
-	Base     *Value `@@`
-	Exponent *Value `( "^" @@ )?`
-}
-
-type OpFactor struct {
-	Operator Operator `@("*" | "/")`
-	Factor   *Factor  `@@`
-}
-
-type Term struct {
-	Left  *Factor     `@@`
-	Right []*OpFactor `@@*`
-}
-
-type OpTerm struct {
-	Operator Operator `@("+" | "-")`
-	Term     *Term    `@@`
-}
-
-type Expression struct {
-	Left  *Term     `@@`
-	Right []*OpTerm `@@*`
-}
-
-// Display
-
-func (o Operator) String() string {
-	switch o {
-	case OpMul:
-		return "*"
-	case OpDiv:
-		return "/"
-	case OpSub:
-		return "-"
-	case OpAdd:
-		return "+"
-	}
-	panic("unsupported operator")
-}
-
-func (v *Value) String() string {
-	if v.Number != nil {
-		return fmt.Sprintf("%g", *v.Number)
-	}
-	// if v.Variable != nil {
-	// 	return *v.Variable
-	// }
-	return "(" + v.Subexpression.String() + ")"
-}
-
-func (f *Factor) String() string {
-	out := f.Base.String()
-	if f.Exponent != nil {
-		out += " ^ " + f.Exponent.String()
-	}
-	return out
-}
-
-func (o *OpFactor) String() string {
-	return fmt.Sprintf("%s %s", o.Operator, o.Factor)
-}
-
-func (t *Term) String() string {
-	out := []string{t.Left.String()}
-	for _, r := range t.Right {
-		out = append(out, r.String())
-	}
-	return strings.Join(out, " ")
-}
-
-func (o *OpTerm) String() string {
-	return fmt.Sprintf("%s %s", o.Operator, o.Term)
-}
-
-func (e *Expression) String() string {
-	out := []string{e.Left.String()}
-	for _, r := range e.Right {
-		out = append(out, r.String())
-	}
-	return strings.Join(out, " ")
-}
-
-// Evaluation
-
-func (o Operator) Eval(l, r float64) float64 {
-	switch o {
-	case OpMul:
-		return l * r
-	case OpDiv:
-		return l / r
-	case OpAdd:
-		return l + r
-	case OpSub:
-		return l - r
-	}
-	panic("unsupported operator")
-}
-
-func (v *Value) Eval(ctx Context) float64 {
-	switch {
-	case v.Number != nil:
-		return *v.Number
-	// case v.Variable != nil:
-	// 	value, ok := ctx[*v.Variable]
-	// 	if !ok {
-	// 		panic("no such variable " + *v.Variable)
-	// 	}
-	// 	return value
-	default:
-		return v.Subexpression.Eval(ctx)
-	}
-}
-
-func (f *Factor) Eval(ctx Context) float64 {
-	b := f.Base.Eval(ctx)
-	if f.Exponent != nil {
-		return math.Pow(b, f.Exponent.Eval(ctx))
-	}
-	return b
-}
-
-func (t *Term) Eval(ctx Context) float64 {
-	n := t.Left.Eval(ctx)
-	for _, r := range t.Right {
-		n = r.Operator.Eval(n, r.Factor.Eval(ctx))
-	}
-	return n
-}
-
-func (e *Expression) Eval(ctx Context) float64 {
-	l := e.Left.Eval(ctx)
-	for _, r := range e.Right {
-		l = r.Operator.Eval(l, r.Term.Eval(ctx))
-	}
-	return l
-}
-
-type Context map[string]float64
-
-var (
-	lexer = stateful.MustSimple([]stateful.Rule{
-		{"Ident", `[a-zA-Z]\w*`, nil},
-		{"Number", `(?:\d*\.)?\d+`, nil},
-		{"Int", `\d+`, nil},
-		{"Punct", `[-[!@#$%^&*()+_={}\|:;"'<,>.?/]|]`, nil},
-	})
-	parser = participle.MustBuild(&Notation{},
-		participle.Lexer(lexer),
-		participle.UseLookahead(2),
-	)
-	// parser = participle.MustBuild(&Expression{})
+	"github.com/travis-g/dice"
 )
 
 func main() {
-	ctx := kong.Parse(&cli,
-		kong.Description("A basic expression parser and evaluator."),
-		kong.UsageOnError(),
-	)
+	ast := flag.Bool("ast", false, "print the AST for the expression as JSON")
+	flag.Parse()
 
-	expr := &Notation{}
-	err := parser.ParseString("", strings.Join(cli.Expression, " "), expr)
-	ctx.FatalIfErrorf(err)
+	expression := strings.Join(flag.Args(), " ")
+	node, err := dice.ParseExpression(context.Background(), expression)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	if cli.AST {
-		json.NewEncoder(os.Stdout).Encode(expr)
-	} else {
-		// fmt.Println(expr, "=", expr.Eval(cli.Set))
+	if *ast {
+		json.NewEncoder(os.Stdout).Encode(node)
+		return
 	}
+	fmt.Println(node)
 }
@@ -2,6 +2,9 @@ package dice
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
 )
 
 type contextKey struct {
@@ -15,14 +18,30 @@ func (k *contextKey) String() string {
 var (
 	CtxKeyTotalRolls = &contextKey{name: "total rolls"}
 	CtxKeyMaxRolls   = &contextKey{name: "max rolls"}
+
+	// CtxKeyParameters is the context key for a map[string]interface{} of
+	// numeric parameters, which bind the variables of expressions, like
+	// @str_mod.
 	CtxKeyParameters = &contextKey{name: "parameters"}
+
+	// CtxKeySource is the context key for a *rand.Rand or *FairSource to use
+	// as the RNG source of dice rolled with the context, in place of the global
+	// Source.
+	CtxKeySource = &contextKey{name: "source"}
+
+	// CtxKeyAuditor is the context key for an Auditor to record every die
+	// rolled with the context.
+	CtxKeyAuditor = &contextKey{name: "auditor"}
+
+	// ctxKeyModifier is the context key for the Modifier that caused a roll.
+	ctxKeyModifier = &contextKey{name: "modifier"}
 )
 
 // NewContextFromContext makes a child context from a given context, including
 // setting the context's maximum rolls and adding a roll counter.
 func NewContextFromContext(ctx context.Context) context.Context {
 	// ensure a maximum roll value is present
-	if _, ok := ctx.Value(CtxKeyMaxRolls).(uint64); !ok {
+	if _, ok := ctxMaxRolls(ctx); !ok {
 		ctx = context.WithValue(ctx, CtxKeyMaxRolls, MaxRolls)
 	}
 	// add a roll counter, if one doesn't exist
@@ -42,17 +61,106 @@ func CtxTotalRolls(ctx context.Context) *uint64 {
 }
 
 // CtxMaxRolls returns the context's maximum allowed number of rolls, or the
-// default.
+// default. The maximum may be set as a uint64 or a non-negative int64.
 func CtxMaxRolls(ctx context.Context) uint64 {
-	if max, ok := ctx.Value(CtxKeyMaxRolls).(uint64); ok {
+	if max, ok := ctxMaxRolls(ctx); ok {
 		return max
 	}
 	return MaxRolls
 }
 
+// ctxMaxRolls returns the context's maximum allowed number of rolls and
+// whether it has a valid one.
+func ctxMaxRolls(ctx context.Context) (uint64, bool) {
+	switch max := ctx.Value(CtxKeyMaxRolls).(type) {
+	case uint64:
+		return max, true
+	case int64:
+		if max >= 0 {
+			return uint64(max), true
+		}
+	}
+	return 0, false
+}
+
+// CtxSource returns the context's RNG source, or the package's global Source
+// if the context does not carry one.
+func CtxSource(ctx context.Context) *rand.Rand {
+	switch source := ctx.Value(CtxKeySource).(type) {
+	case *rand.Rand:
+		if source != nil {
+			return source
+		}
+	case *FairSource:
+		if source != nil {
+			return rand.New(source)
+		}
+	}
+	return Source
+}
+
+// CtxFairSource returns the context's FairSource, or nil if the context is not
+// rolling fairly.
+func CtxFairSource(ctx context.Context) *FairSource {
+	if source, ok := ctx.Value(CtxKeySource).(*FairSource); ok {
+		return source
+	}
+	return nil
+}
+
+// CtxAuditor returns the context's Auditor, or nil if rolls made with the
+// context are not audited.
+func CtxAuditor(ctx context.Context) Auditor {
+	if auditor, ok := ctx.Value(CtxKeyAuditor).(Auditor); ok {
+		return auditor
+	}
+	return nil
+}
+
+// CtxParameters returns the context's parameters, which bind the variables of
+// expressions evaluated with the context.
 func CtxParameters(ctx context.Context) map[string]interface{} {
 	if params, ok := ctx.Value(CtxKeyParameters).(map[string]interface{}); ok {
 		return params
 	}
 	return make(map[string]interface{})
 }
+
+// CtxParameter returns the numeric value of the context's parameter with the
+// given name. An ErrUnboundVariable error is returned if the parameter is not
+// set.
+func CtxParameter(ctx context.Context, name string) (float64, error) {
+	v, ok := CtxParameters(ctx)[name]
+	if !ok {
+		return 0, &ErrUnboundVariable{Name: name}
+	}
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int8:
+		return float64(v), nil
+	case int16:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case uint8:
+		return float64(v), nil
+	case uint16:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case json.Number:
+		return v.Float64()
+	}
+	return 0, fmt.Errorf("parameter %q is a %T, not a number", name, v)
+}
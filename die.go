@@ -4,7 +4,12 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"sync/atomic"
+
+	"github.com/pkg/errors"
 )
 
 // Die represents an internally-typed die. If Result is a non-nil pointer, it
@@ -14,12 +19,22 @@ type Die struct {
 	Type DieType `json:"type,omitempty" mapstructure:"type"`
 	Size int     `json:"size" mapstructure:"size"`
 
+	// Faces are the faces of a custom die, in order.
+	Faces []Face `json:"faces,omitempty" mapstructure:"faces"`
+
+	// Weights are the relative weights of each of the die's faces, if the die
+	// is weighted. A face with a weight of 0 is never rolled.
+	Weights []float64 `json:"weights,omitempty" mapstructure:"weights"`
+
 	Rerolls int `json:"rerolls" mapstructure:"rerolls"`
 	*Result `json:"result,omitempty" mapstructure:"result"`
 
 	Modifiers ModifierList `json:"modifiers,omitempty" mapstructure:"modifiers"`
 
 	parent Roller
+
+	// cause is the modifier that added the die to its group, if any.
+	cause Modifier
 }
 
 // NewDie creates a new die off of a properties list. It will tweak the
@@ -40,8 +55,10 @@ func NewDieWithParent(props *RollerProperties, parent Roller) (Roller, error) {
 	die := &Die{
 		Type:      props.Type,
 		Size:      props.Size,
+		Faces:     props.Faces,
+		Weights:   props.Weights,
 		Result:    props.Result,
-		Modifiers: props.DieModifiers,
+		Modifiers: props.DieModifiers.Copy(),
 	}
 
 	if parent != nil {
@@ -50,38 +67,115 @@ func NewDieWithParent(props *RollerProperties, parent Roller) (Roller, error) {
 	return die, nil
 }
 
+// NewCustomDie creates a new custom die off of a properties list. The die's
+// size is set to its number of faces.
+func NewCustomDie(props *RollerProperties, parent Roller) (Roller, error) {
+	if len(props.Faces) == 0 {
+		return nil, errors.New("custom die has no faces")
+	}
+	props.Size = len(props.Faces)
+	if props.Weights != nil {
+		if err := validWeights(props.Weights, props.Size); err != nil {
+			return nil, err
+		}
+	}
+	return NewDieWithParent(props, parent)
+}
+
+// NewPercentileDie creates a new percentile die (d%) off of a properties list.
+// A percentile die is rolled as a tens die and a units die, each numbered 0 to
+// 9, where a roll of 00 is 100.
+func NewPercentileDie(props *RollerProperties, parent Roller) (Roller, error) {
+	props.Size = 100
+	return NewDieWithParent(props, parent)
+}
+
+// NewDigitDie creates a new digit die off of a properties list. Each digit of
+// a digit die's value is supplied by its own die, like the tens and units d6s
+// of a d66, so the die's size must repeat a single digit.
+func NewDigitDie(props *RollerProperties, parent Roller) (Roller, error) {
+	if _, _, ok := digitSize(props.Size); !ok {
+		return nil, errors.Errorf("invalid digit die size %d", props.Size)
+	}
+	return NewDieWithParent(props, parent)
+}
+
+// NewWeightedDie creates a new weighted die off of a properties list. The die
+// has faces numbered 1 to its size, as with a polyhedral die, each with a
+// weight from the properties list.
+func NewWeightedDie(props *RollerProperties, parent Roller) (Roller, error) {
+	if err := validWeights(props.Weights, props.Size); err != nil {
+		return nil, err
+	}
+	return NewDieWithParent(props, parent)
+}
+
+// validWeights returns an error if weights are not valid for a die with the
+// given number of faces.
+func validWeights(weights []float64, faces int) error {
+	if len(weights) != faces {
+		return errors.Errorf("%d weights for a die with %d faces", len(weights), faces)
+	}
+	var total float64
+	for _, w := range weights {
+		if w < 0 {
+			return errors.Errorf("negative weight %v", w)
+		}
+		total += w
+	}
+	if total == 0 {
+		return errors.New("weighted die has no face with a positive weight")
+	}
+	return nil
+}
+
 // Roll rolls a die based on the die's size and type and calculates a value.
+// The context's source is used to roll the die if it has one, otherwise the
+// package's global Source is used. The roll is recorded by the context's
+// Auditor, if it has one.
 func (d *Die) Roll(ctx context.Context) error {
 	if d == nil {
 		return ErrNilDie
 	}
 
 	// Check if rolled too many times already
-	var maxRolls = int64(MaxRolls)
-	if ctxMaxRolls, ok := ctx.Value(CtxKeyMaxRolls).(int64); ok {
-		maxRolls = ctxMaxRolls
-	}
-
-	if *CtxTotalRolls(ctx) >= uint64(maxRolls) {
+	if *CtxTotalRolls(ctx) >= CtxMaxRolls(ctx) {
 		return ErrMaxRolls
 	}
 
 	// bump context roll count
 	atomic.AddUint64(CtxTotalRolls(ctx), 1)
 
-	if d.Size == 0 {
+	// record the roll once its result is complete
+	defer audit(ctx, d)
+
+	if d.Size == 0 && d.Type != TypeCustom || d.faces() == 0 {
 		d.Result = NewResult(0)
 		return nil
 	}
 
+	var x int
+	var nonce *uint64
+	if fair := CtxFairSource(ctx); fair != nil {
+		var n uint64
+		x, n = fair.Intn(d.outcomes())
+		nonce = &n
+	} else {
+		x = CtxSource(ctx).Intn(d.outcomes())
+	}
+	i := d.index(x)
+	d.Result = NewResult(d.face(i))
+	d.Result.Nonce = nonce
 	switch d.Type {
+	case TypeCustom:
+		// custom dice have no default criticals
+		d.Result.Symbol = d.Faces[i].Symbol
+		return nil
 	case TypeFudge:
-		d.Result = NewResult(float64(Source.Intn(int(d.Size*2+1)) - int(d.Size)))
 		if d.Result.Value == -float64(d.Size) {
 			d.CritFailure = true
 		}
 	default:
-		d.Result = NewResult(float64(1 + Source.Intn(int(d.Size))))
 		if d.Result.Value == 1 {
 			d.CritFailure = true
 		}
@@ -94,6 +188,183 @@ func (d *Die) Roll(ctx context.Context) error {
 	return nil
 }
 
+// faces returns the number of faces of the die.
+func (d *Die) faces() int {
+	switch d.Type {
+	case TypeFudge:
+		return d.Size*2 + 1
+	case TypeCustom:
+		return len(d.Faces)
+	case TypeDigits:
+		digits, base, _ := digitSize(d.Size)
+		faces := 1
+		for j := 0; j < digits; j++ {
+			faces *= base
+		}
+		return faces
+	default:
+		return d.Size
+	}
+}
+
+// face returns the value of the die's i-th face, counting from 0.
+func (d *Die) face(i int) float64 {
+	switch d.Type {
+	case TypeFudge:
+		return float64(i - d.Size)
+	case TypeCustom:
+		return d.Faces[i].Value
+	case TypePercentile:
+		// the tens and units dice of i, where 00 is 100
+		if i == 0 {
+			return 100
+		}
+		return float64(i)
+	case TypeDigits:
+		// each digit of i in the digit dice's base is a die's roll
+		_, base, _ := digitSize(d.Size)
+		var value, place float64 = 0, 1
+		for n := d.faces(); n > 1; n /= base {
+			value += float64(i%base+1) * place
+			i /= base
+			place *= 10
+		}
+		return value
+	default:
+		return float64(1 + i)
+	}
+}
+
+// weightOutcomes is the number of equally likely outcomes a roll of a weighted
+// die is drawn from, before being mapped to a face by its weight.
+const weightOutcomes = 1 << 30
+
+// outcomes returns the number of equally likely outcomes a roll of the die is
+// drawn from.
+func (d *Die) outcomes() int {
+	if len(d.Weights) > 0 {
+		return weightOutcomes
+	}
+	return d.faces()
+}
+
+// index returns the index of the face rolled by the outcome x of a draw from
+// the die's outcomes.
+func (d *Die) index(x int) int {
+	if len(d.Weights) == 0 {
+		return x
+	}
+	var total float64
+	for _, w := range d.Weights {
+		total += w
+	}
+	target := float64(x) / weightOutcomes * total
+	last := 0
+	var cumulative float64
+	for i, w := range d.Weights {
+		if w <= 0 {
+			continue
+		}
+		cumulative += w
+		if target < cumulative {
+			return i
+		}
+		last = i
+	}
+	// rounding may leave the target beyond the final face
+	return last
+}
+
+// ordered returns whether the die's faces can all be rolled and are distinct
+// and in ascending order.
+func (d *Die) ordered() bool {
+	switch d.Type {
+	case TypeCustom, TypePercentile:
+		return false
+	}
+	return len(d.Weights) == 0
+}
+
+// possible returns whether the die's i-th face can be rolled.
+func (d *Die) possible(i int) bool {
+	return len(d.Weights) == 0 || d.Weights[i] > 0
+}
+
+// faceRange returns the lowest and highest values of the die's faces that can
+// be rolled.
+func (d *Die) faceRange() (float64, float64) {
+	if d.faces() == 0 {
+		return 0, 0
+	}
+	if d.ordered() {
+		return d.face(0), d.face(d.faces() - 1)
+	}
+	first := true
+	var lo, hi float64
+	for i := 0; i < d.faces(); i++ {
+		if !d.possible(i) {
+			continue
+		}
+		v := d.face(i)
+		if first || v < lo {
+			lo = v
+		}
+		if first || v > hi {
+			hi = v
+		}
+		first = false
+	}
+	return lo, hi
+}
+
+// distinctFaces returns the number of distinct faces of the die that can be
+// rolled.
+func (d *Die) distinctFaces() int {
+	var settled []Modifier
+	for _, mod := range d.Modifiers {
+		switch m := mod.(type) {
+		case *ExplodeModifier:
+			if m.Compound || m.Penetrate {
+				// chained rolls can add up to any number of values
+				return math.MaxInt32
+			}
+		case *RerollModifier:
+			if !m.Once {
+				settled = append(settled, m)
+			}
+		case *ClampModifier:
+			settled = append(settled, m)
+		}
+	}
+	if d.ordered() && len(settled) == 0 {
+		return d.faces()
+	}
+	distinct := make(map[Face]bool, d.faces())
+faces:
+	for i := 0; i < d.faces(); i++ {
+		if !d.possible(i) {
+			continue
+		}
+		f := Face{Value: d.face(i)}
+		if d.Type == TypeCustom {
+			f = d.Faces[i]
+		}
+		for _, mod := range settled {
+			switch m := mod.(type) {
+			case *RerollModifier:
+				// the face is always rerolled
+				if match, _ := m.match(f.Value); match {
+					continue faces
+				}
+			case *ClampModifier:
+				f.Value = m.clamp(f.Value)
+			}
+		}
+		distinct[f] = true
+	}
+	return len(distinct)
+}
+
 // reset resets a Die's properties so that it can be re-rolled from scratch.
 func (d *Die) reset() {
 	d.Result = nil
@@ -116,23 +387,32 @@ func (d *Die) FullRoll(ctx context.Context) error {
 		return err
 	}
 
-	// Apply modifiers
-	for i := 0; i < len(d.Modifiers); i++ {
-		err := d.Modifiers[i].Apply(ctx, d)
-		switch {
-		// die rerolled, so restart validation checks with new modifiers
-		case err == ErrRerolled:
-			i = -1
-			// i++ => 0 to restart from first modifier
-			break
-		case err != nil:
+	// Apply modifiers, rerolling before any others, whatever order they are
+	// written in, and leaving clamps until the die's value is settled
+	if err := d.applyRerolls(ctx); err != nil {
+		return err
+	}
+	for _, mod := range d.Modifiers {
+		switch mod.(type) {
+		case *RerollModifier, *ClampModifier:
+			continue
+		}
+		if err := mod.Apply(ctx, d); err != nil {
 			return err
 		}
 	}
+	for _, mod := range d.Modifiers {
+		if m, ok := mod.(*ClampModifier); ok {
+			if err := m.Apply(ctx, d); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
-// Reroll performs a reroll after resetting a Die.
+// Reroll performs a reroll after resetting a Die. The replaced value is kept in
+// the new Result's History along with the modifier that caused the reroll.
 func (d *Die) Reroll(ctx context.Context) error {
 	if d == nil {
 		return ErrNilDie
@@ -141,25 +421,66 @@ func (d *Die) Reroll(ctx context.Context) error {
 		return ErrUnrolled
 	}
 
+	prior := d.Result
 	d.Result = nil
 	d.Rerolls++
 	// reroll without reapplying all modifiers
-	return d.Roll(ctx)
+	if err := d.Roll(ctx); err != nil {
+		return err
+	}
+	var reason string
+	if m, ok := ctx.Value(ctxKeyModifier).(Modifier); ok {
+		reason = m.String()
+	}
+	d.Result.History = append(prior.History, PriorResult{
+		Value:  prior.Value,
+		Kind:   ReplacementReroll,
+		Reason: reason,
+		Nonce:  prior.Nonce,
+	})
+	return nil
 }
 
 // String returns an expression-like representation of a rolled die or its type,
-// if it has not been rolled.
+// if it has not been rolled. A die with replaced values is rendered with its
+// history, like [1→4].
 func (d *Die) String() string {
 	if d == nil {
 		return ""
 	}
 	if d.Result != nil {
 		total, _ := d.Total(context.Background())
-		return fmt.Sprintf("%.0f", total)
+		if len(d.Result.History) == 0 {
+			if d.Result.Symbol != "" && !d.Result.Dropped {
+				return d.Result.Symbol
+			}
+			return fmt.Sprintf("%.0f", total)
+		}
+		var b strings.Builder
+		b.WriteString("[")
+		for _, prior := range d.Result.History {
+			fmt.Fprintf(&b, "%.0f→", prior.Value)
+		}
+		fmt.Fprintf(&b, "%.0f]", total)
+		return b.String()
 	}
 	switch d.Type {
-	case TypePolyhedron:
+	case TypePolyhedron, TypeDigits:
 		return fmt.Sprintf("d%d%s", d.Size, d.Modifiers)
+	case TypeCustom:
+		faces := make([]string, len(d.Faces))
+		for i, f := range d.Faces {
+			faces[i] = f.String()
+		}
+		return fmt.Sprintf("d{%s}%s", strings.Join(faces, ","), d.Modifiers)
+	case TypePercentile:
+		return fmt.Sprintf("d%%%s", d.Modifiers)
+	case TypeWeighted:
+		weights := make([]string, len(d.Weights))
+		for i, w := range d.Weights {
+			weights[i] = strconv.FormatFloat(w, 'f', -1, 64)
+		}
+		return fmt.Sprintf("d%d{%s}%s", d.Size, strings.Join(weights, ","), d.Modifiers)
 	case TypeFudge:
 		if d.Size == 1 {
 			return fmt.Sprintf("dF%s", d.Modifiers)
@@ -1,4 +1,232 @@
 package dice
 
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
 // ensure Die implements Roller
 var _ Roller = (*Die)(nil)
+
+func TestDie_Roll_ContextSource(t *testing.T) {
+	roll := func(seed int64) []float64 {
+		ctx := context.WithValue(context.Background(), CtxKeySource, rand.New(rand.NewSource(seed)))
+		values := make([]float64, 10)
+		for i := range values {
+			die := &Die{Size: 20}
+			if err := die.Roll(ctx); err != nil {
+				t.Fatal(err)
+			}
+			values[i] = die.Result.Value
+		}
+		return values
+	}
+	if a, b := roll(1), roll(1); !reflect.DeepEqual(a, b) {
+		t.Errorf("rolls with equally seeded sources differ: %v, %v", a, b)
+	}
+}
+
+func TestDie_Roll_MaxRolls(t *testing.T) {
+	for _, max := range []interface{}{uint64(2), int64(2)} {
+		ctx := NewContextFromContext(context.WithValue(context.Background(), CtxKeyMaxRolls, max))
+		die := &Die{Size: 6}
+		for i := 0; i < 2; i++ {
+			if err := die.Roll(ctx); err != nil {
+				t.Fatalf("%T: roll %d: %v", max, i, err)
+			}
+		}
+		if err := die.Roll(ctx); err != ErrMaxRolls {
+			t.Errorf("%T: got %v, want %v", max, err, ErrMaxRolls)
+		}
+	}
+
+	// a negative maximum is invalid and ignored
+	ctx := context.WithValue(context.Background(), CtxKeyMaxRolls, int64(-1))
+	if max := CtxMaxRolls(ctx); max != MaxRolls {
+		t.Errorf("CtxMaxRolls() = %v, want %v", max, MaxRolls)
+	}
+}
+
+func TestDie_Reroll_History(t *testing.T) {
+	ctx := context.WithValue(context.Background(), CtxKeySource, NewSeededSource(1))
+	die := &Die{Size: 6}
+	if err := die.Roll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	values := []float64{die.Result.Value}
+	mod := &RerollModifier{CompareTarget: &CompareTarget{Compare: EQL, Target: 7}}
+	for i := 0; i < 3; i++ {
+		if err := die.Reroll(withModifier(ctx, mod)); err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, die.Result.Value)
+	}
+	if err := die.Reroll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []PriorResult{
+		{Value: values[0], Kind: ReplacementReroll, Reason: "r7"},
+		{Value: values[1], Kind: ReplacementReroll, Reason: "r7"},
+		{Value: values[2], Kind: ReplacementReroll, Reason: "r7"},
+		{Value: values[3], Kind: ReplacementReroll},
+	}
+	if !reflect.DeepEqual(die.Result.History, want) {
+		t.Errorf("history = %+v, want %+v", die.Result.History, want)
+	}
+	if die.Rerolls != len(want) {
+		t.Errorf("rerolls = %d, want %d", die.Rerolls, len(want))
+	}
+}
+
+func TestDie_Roll_Custom(t *testing.T) {
+	props, err := ParseNotation(context.Background(), "d{1,1,2,Skull}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRoller(&props)
+	if err != nil {
+		t.Fatal(err)
+	}
+	die := r.(*Die)
+	if got, want := die.String(), "d{1,1,2,Skull}"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	ctx := context.WithValue(context.Background(), CtxKeySource, NewSeededSource(1))
+	counts := make(map[string]int)
+	for i := 0; i < 400; i++ {
+		if err := die.FullRoll(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if die.CritSuccess || die.CritFailure {
+			t.Errorf("custom die rolled a default critical: %+v", die.Result)
+		}
+		counts[die.String()]++
+	}
+	if len(counts) != 3 || counts["Skull"] == 0 || counts["1"] <= counts["2"] {
+		t.Errorf("rolled faces %v, want 1 twice as often as 2 and Skull", counts)
+	}
+
+	if _, err := NewRoller(&RollerProperties{Type: TypeCustom}); err == nil {
+		t.Error("created a custom die without faces")
+	}
+}
+
+func TestDie_Roll_Weighted(t *testing.T) {
+	props, err := ParseNotation(context.Background(), "d4{0,1,0,3}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRoller(&props)
+	if err != nil {
+		t.Fatal(err)
+	}
+	die := r.(*Die)
+	if got, want := die.String(), "d4{0,1,0,3}"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	ctx := context.WithValue(context.Background(), CtxKeySource, NewSeededSource(1))
+	counts := make(map[float64]int)
+	const rolls = 4000
+	for i := 0; i < rolls; i++ {
+		if err := die.Roll(ctx); err != nil {
+			t.Fatal(err)
+		}
+		counts[die.Result.Value]++
+	}
+	if len(counts) != 2 {
+		t.Fatalf("rolled faces %v, want only 2 and 4", counts)
+	}
+	// a 4 is rolled 3/4 of the time; allow several standard deviations
+	if n := counts[4]; n < rolls*3/4-150 || n > rolls*3/4+150 {
+		t.Errorf("rolled %d 4s of %d, want about %d", n, rolls, rolls*3/4)
+	}
+
+	// weights apply to custom dice too
+	custom, err := NewRoller(&RollerProperties{
+		Type:    TypeCustom,
+		Faces:   []Face{{Symbol: "hit"}, {Symbol: "miss"}},
+		Weights: []float64{1, 0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if err := custom.Roll(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if got := custom.String(); got != "hit" {
+			t.Fatalf("rolled %q with a weight of 0", got)
+		}
+	}
+
+	for _, props := range []*RollerProperties{
+		{Type: TypeWeighted, Size: 3, Weights: []float64{1, 1}},
+		{Type: TypeWeighted, Size: 2, Weights: []float64{0, 0}},
+		{Type: TypeWeighted, Size: 2, Weights: []float64{2, -1}},
+		{Type: TypeCustom, Faces: []Face{{Value: 1}}, Weights: []float64{1, 1}},
+	} {
+		if _, err := NewRoller(props); err == nil {
+			t.Errorf("created a die with invalid weights %v", props.Weights)
+		}
+	}
+}
+
+func TestDie_Roll_Digits(t *testing.T) {
+	tests := []struct {
+		notation string
+		str      string
+		faces    int
+		valid    func(v float64) bool
+	}{
+		{"d%", "d%", 100, func(v float64) bool { return v >= 1 && v <= 100 && v == float64(int(v)) }},
+		{"d66", "d66", 36, func(v float64) bool {
+			tens, units := int(v)/10, int(v)%10
+			return tens >= 1 && tens <= 6 && units >= 1 && units <= 6
+		}},
+		{"d666", "d666", 216, func(v float64) bool {
+			for n := int(v); n > 0; n /= 10 {
+				if d := n % 10; d < 1 || d > 6 {
+					return false
+				}
+			}
+			return v >= 111
+		}},
+	}
+	for _, tt := range tests {
+		props, err := ParseNotation(context.Background(), tt.notation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewRoller(&props)
+		if err != nil {
+			t.Fatal(err)
+		}
+		die := r.(*Die)
+		if got := die.String(); got != tt.str {
+			t.Errorf("String() = %q, want %q", got, tt.str)
+		}
+		ctx := context.WithValue(context.Background(), CtxKeySource, NewSeededSource(1))
+		seen := make(map[float64]bool)
+		for i := 0; i < tt.faces*20; i++ {
+			if err := die.Roll(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.valid(die.Result.Value) {
+				t.Fatalf("%s rolled %v", tt.notation, die.Result.Value)
+			}
+			seen[die.Result.Value] = true
+		}
+		if len(seen) != tt.faces {
+			t.Errorf("%s rolled %d distinct values, want %d", tt.notation, len(seen), tt.faces)
+		}
+	}
+
+	if _, err := NewRoller(&RollerProperties{Type: TypeDigits, Size: 65}); err == nil {
+		t.Error("created a digit die of size 65")
+	}
+}
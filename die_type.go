@@ -1,5 +1,7 @@
 package dice
 
+import "strconv"
+
 // DieType is the enum of types that a die or dice can be
 type DieType string
 
@@ -8,6 +10,10 @@ const (
 	// Concrete dice types: these can be used to instantiate a new rollable.
 	TypePolyhedron DieType = ""
 	TypeFudge      DieType = "fudge"
+	TypeCustom     DieType = "custom"
+	TypeWeighted   DieType = "weighted"
+	TypePercentile DieType = "percentile"
+	TypeDigits     DieType = "digits"
 
 	// Meta dice types: these are used to classify rollable groups and unknown
 	// dice.
@@ -20,7 +26,46 @@ func (t DieType) String() string {
 		return "polyhedron"
 	case TypeFudge:
 		return "fudge"
+	case TypeCustom:
+		return "custom"
+	case TypeWeighted:
+		return "weighted"
+	case TypePercentile:
+		return "percentile"
+	case TypeDigits:
+		return "digits"
 	default:
 		return "unknown"
 	}
 }
+
+// digitSize returns the number of digits and the number of faces of each
+// digit's die for the size of a digit die, like d66 or d666, whose size repeats
+// a single digit at least twice. ok is false if size is not a valid digit die
+// size.
+func digitSize(size int) (digits, base int, ok bool) {
+	s := strconv.Itoa(size)
+	if len(s) < 2 || s[0] < '2' {
+		return 0, 0, false
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i] != s[0] {
+			return 0, 0, false
+		}
+	}
+	return len(s), int(s[0] - '0'), true
+}
+
+// A Face is a face of a custom die. A face is either numeric, or a symbol with
+// a Value of 0.
+type Face struct {
+	Value  float64 `json:"value"`
+	Symbol string  `json:"symbol,omitempty"`
+}
+
+func (f Face) String() string {
+	if f.Symbol != "" {
+		return f.Symbol
+	}
+	return strconv.FormatFloat(f.Value, 'f', -1, 64)
+}
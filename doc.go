@@ -2,7 +2,8 @@
 Package dice implements virtualized standard polyhedral and specialty game dice.
 The dice roll calculations are intended to be cryptographically pseudo-random
 through use of crypto/rand, but the entropy source used by the package is
-globally configurable.
+globally configurable, and can be overridden per context. A FairSource can be
+used to make provably fair rolls that can be verified after the fact.
 
 # Dice Notation
 
@@ -1,6 +1,11 @@
 package dice
 
-import "errors"
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
 
 var (
 	// ErrSizeZero is returned when an attempt to create or roll a 0-sized die
@@ -54,13 +59,43 @@ func (e *ErrNotImplemented) Error() string {
 	return e.message
 }
 
+// ErrUnboundVariable is returned when an expression references a variable,
+// like @str_mod, that is not bound in the context's parameters
+// (CtxKeyParameters).
+type ErrUnboundVariable struct {
+	Name string
+}
+
+func (e *ErrUnboundVariable) Error() string {
+	return "unbound variable " + quote("@"+e.Name)
+}
+
+// A ParseErrorKind classifies the cause of an ErrParseError.
+type ParseErrorKind string
+
+// Kinds of parse errors.
+const (
+	ParseErrorUnknown         ParseErrorKind = ""
+	ParseErrorUnexpectedToken ParseErrorKind = "unexpected token"
+	ParseErrorUnexpectedEnd   ParseErrorKind = "unexpected end"
+	ParseErrorIllegalChar     ParseErrorKind = "illegal character"
+	ParseErrorInvalidNumber   ParseErrorKind = "invalid number"
+	ParseErrorUnknownModifier ParseErrorKind = "unknown modifier"
+	ParseErrorNotNotation     ParseErrorKind = "not a notation"
+)
+
 // ErrParseError is an error encountered when parsing a string into dice
-// notation.
+// notation. Errors returned by the package's parsers set a Kind and the Span
+// of the offending part of the Notation, and may suggest a fix.
 type ErrParseError struct {
 	Notation     string
 	NotationElem string
 	ValueElem    string
 	Message      string
+
+	Kind       ParseErrorKind
+	Span       Span
+	Suggestion string
 }
 
 func (e *ErrParseError) Error() string {
@@ -70,6 +105,48 @@ func (e *ErrParseError) Error() string {
 			quote(e.ValueElem) + " as " +
 			quote(e.NotationElem)
 	}
-	return "parsing dice " +
-		quote(e.Notation) + e.Message
+	msg := "parsing dice " + quote(e.Notation) + e.Message
+	if e.Kind != ParseErrorUnknown {
+		msg += " at offset " + strconv.Itoa(e.Span.Start)
+	}
+	return msg
+}
+
+// Caret returns the parsed notation with a caret-underline beneath the span
+// of the error:
+//
+//	3d6xyz
+//	   ^^^
+//
+// An empty string is returned if the error has no Kind, as its span is
+// unknown.
+func (e *ErrParseError) Caret() string {
+	if e.Kind == ParseErrorUnknown {
+		return ""
+	}
+	start, end := e.Span.Start, e.Span.End
+	if start > len(e.Notation) {
+		start = len(e.Notation)
+	}
+	if end > len(e.Notation) {
+		end = len(e.Notation)
+	}
+
+	var b strings.Builder
+	b.WriteString(e.Notation)
+	b.WriteString("\n")
+	// preserve tabs so that the caret aligns with the notation above it
+	for _, r := range e.Notation[:start] {
+		if r == '\t' {
+			b.WriteRune('\t')
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	width := utf8.RuneCountInString(e.Notation[start:end])
+	if width < 1 {
+		width = 1
+	}
+	b.WriteString(strings.Repeat("^", width))
+	return b.String()
 }
@@ -0,0 +1,166 @@
+package dice
+
+import (
+	"crypto/hmac"
+	crypto "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// Errors returned when verifying provably fair rolls.
+var (
+	// ErrCommitmentMismatch is returned when a revealed server seed does not
+	// match the commitment made before rolling.
+	ErrCommitmentMismatch = errors.New("server seed does not match commitment")
+
+	// ErrUnverifiable is returned when a die's result cannot be verified, as
+	// it was not rolled with a FairSource.
+	ErrUnverifiable = errors.New("die was not rolled fairly")
+)
+
+// A FairSource is a provably fair source of rolls using a commit/reveal
+// scheme. Before rolling, the server commits to a secret server seed by
+// publishing its SHA-256 hash, and the client supplies a client seed. Each roll
+// then derives its value from
+//
+//	HMAC-SHA256(server seed, client seed + ":" + nonce)
+//
+// where nonce counts up from 0 for each roll. The digest is interpreted as a
+// big-endian unsigned integer, and taken modulo the number of faces of the die.
+//
+// Dice rolled with a FairSource set as the context's source (CtxKeySource)
+// record the nonce used within their Result. Once the server seed is revealed,
+// anyone can check it against the commitment and replay the rolls with a
+// source rebuilt from the seeds (FairReveal.Source).
+//
+// A FairSource is safe for concurrent use.
+type FairSource struct {
+	serverSeed []byte
+	clientSeed string
+	nonce      uint64
+}
+
+// NewFairSource returns a FairSource for a server seed and client seed.
+func NewFairSource(serverSeed []byte, clientSeed string) *FairSource {
+	return &FairSource{
+		serverSeed: serverSeed,
+		clientSeed: clientSeed,
+	}
+}
+
+// GenerateFairSource returns a FairSource for the client seed with a new
+// server seed made of 32 bytes from the system's CSPRNG.
+func GenerateFairSource(clientSeed string) (*FairSource, error) {
+	seed := make([]byte, 32)
+	if _, err := crypto.Read(seed); err != nil {
+		return nil, err
+	}
+	return NewFairSource(seed, clientSeed), nil
+}
+
+// Commitment returns the hex-encoded SHA-256 hash of the server seed, which
+// should be published before any rolls are made.
+func (s *FairSource) Commitment() string {
+	return commitment(s.serverSeed)
+}
+
+// Nonce returns the nonce that will be used by the next roll.
+func (s *FairSource) Nonce() uint64 {
+	return atomic.LoadUint64(&s.nonce)
+}
+
+// Reveal returns the seeds needed to verify the source's rolls. The server
+// seed must be kept secret until all rolls that rely on it are complete.
+func (s *FairSource) Reveal() *FairReveal {
+	return &FairReveal{
+		Commitment: s.Commitment(),
+		ServerSeed: hex.EncodeToString(s.serverSeed),
+		ClientSeed: s.clientSeed,
+	}
+}
+
+// Intn returns a provably fair random integer in [0,n) along with the nonce
+// used to derive it. It panics if n <= 0.
+func (s *FairSource) Intn(n int) (int, uint64) {
+	nonce := atomic.AddUint64(&s.nonce, 1) - 1
+	return fairValue(s.serverSeed, s.clientSeed, nonce, n), nonce
+}
+
+// Int63 implements rand.Source so that a FairSource can be used where a
+// *rand.Rand is expected, each value consuming a nonce. Values drawn this way
+// are not recorded, so prefer rolling dice with the FairSource directly.
+func (s *FairSource) Int63() int64 {
+	return int64(s.Uint64() & ^uint64(1<<63))
+}
+
+// Uint64 satisfies the rand.Source64 interface.
+func (s *FairSource) Uint64() uint64 {
+	nonce := atomic.AddUint64(&s.nonce, 1) - 1
+	digest := fairDigest(s.serverSeed, s.clientSeed, nonce)
+	return new(big.Int).SetBytes(digest[:8]).Uint64()
+}
+
+// Seed is a noop; a FairSource is seeded when created.
+func (s *FairSource) Seed(int64) {
+	// noop; seeds are fixed by the commitment
+}
+
+// FairReveal is the set of seeds revealed after rolling with a FairSource,
+// used to verify the rolls made.
+type FairReveal struct {
+	Commitment string `json:"commitment"`
+	ServerSeed string `json:"server_seed"`
+	ClientSeed string `json:"client_seed"`
+}
+
+// Verify checks that the revealed server seed matches the commitment.
+func (r *FairReveal) Verify() error {
+	seed, err := hex.DecodeString(r.ServerSeed)
+	if err != nil {
+		return errors.Wrap(err, "decoding server seed")
+	}
+	if !hmac.Equal([]byte(commitment(seed)), []byte(r.Commitment)) {
+		return ErrCommitmentMismatch
+	}
+	return nil
+}
+
+// Source checks that the revealed server seed matches the commitment, and
+// returns a new FairSource for the revealed seeds. Rolls made with the source
+// start again from nonce 0, so the source's original rolls can be replayed to
+// verify them.
+func (r *FairReveal) Source() (*FairSource, error) {
+	if err := r.Verify(); err != nil {
+		return nil, err
+	}
+	seed, err := hex.DecodeString(r.ServerSeed)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding server seed")
+	}
+	return NewFairSource(seed, r.ClientSeed), nil
+}
+
+func commitment(serverSeed []byte) string {
+	sum := sha256.Sum256(serverSeed)
+	return hex.EncodeToString(sum[:])
+}
+
+func fairDigest(serverSeed []byte, clientSeed string, nonce uint64) []byte {
+	mac := hmac.New(sha256.New, serverSeed)
+	mac.Write([]byte(clientSeed + ":" + strconv.FormatUint(nonce, 10)))
+	return mac.Sum(nil)
+}
+
+// fairValue derives an integer in [0,n) for a nonce.
+func fairValue(serverSeed []byte, clientSeed string, nonce uint64, n int) int {
+	if n <= 0 {
+		panic("invalid argument to fairValue")
+	}
+	digest := new(big.Int).SetBytes(fairDigest(serverSeed, clientSeed, nonce))
+	return int(digest.Mod(digest, big.NewInt(int64(n))).Int64())
+}
@@ -0,0 +1,83 @@
+package dice
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+var _ = (rand.Source64)(&FairSource{})
+
+func TestFairSource_Roll(t *testing.T) {
+	// expected values derived independently from
+	// HMAC-SHA256(0x00ff, "abc:<nonce>") mod faces
+	fair := NewFairSource([]byte{0x00, 0xff}, "abc")
+	ctx := context.WithValue(context.Background(), CtxKeySource, fair)
+	testCases := []struct {
+		size int
+		want float64
+	}{
+		{6, 2},
+		{6, 3},
+		{6, 2},
+		{20, 3},
+	}
+	for i, tc := range testCases {
+		d := &Die{Size: tc.size}
+		if err := d.Roll(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if d.Result.Value != tc.want {
+			t.Errorf("roll %d: d%d rolled %v, want %v", i, tc.size, d.Result.Value, tc.want)
+		}
+		if d.Result.Nonce == nil || *d.Result.Nonce != uint64(i) {
+			t.Errorf("roll %d: nonce = %v, want %d", i, d.Result.Nonce, i)
+		}
+	}
+	if fair.Nonce() != uint64(len(testCases)) {
+		t.Errorf("next nonce = %d, want %d", fair.Nonce(), len(testCases))
+	}
+}
+
+func TestFairReveal_Source(t *testing.T) {
+	fair, err := GenerateFairSource("client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), CtxKeySource, fair)
+	var rolls []*Die
+	for i := 0; i < 20; i++ {
+		d := &Die{Type: TypePolyhedron, Size: 20}
+		if err := d.Roll(ctx); err != nil {
+			t.Fatal(err)
+		}
+		rolls = append(rolls, d)
+	}
+
+	// the rebuilt source replays the rolls from nonce 0
+	reveal := fair.Reveal()
+	source, err := reveal.Source()
+	if err != nil {
+		t.Fatalf("reveal does not match its commitment: %v", err)
+	}
+	ctx = context.WithValue(context.Background(), CtxKeySource, source)
+	for i, want := range rolls {
+		d := &Die{Type: TypePolyhedron, Size: 20}
+		if err := d.Roll(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if d.Result.Value != want.Result.Value || *d.Result.Nonce != *want.Result.Nonce {
+			t.Errorf("roll %d: replayed %v with nonce %d, want %v with nonce %d",
+				i, d.Result.Value, *d.Result.Nonce, want.Result.Value, *want.Result.Nonce)
+		}
+	}
+
+	tampered := *reveal
+	tampered.ServerSeed = "00"
+	if err := tampered.Verify(); err != ErrCommitmentMismatch {
+		t.Errorf("tampered seed: got %v, want %v", err, ErrCommitmentMismatch)
+	}
+	if _, err := tampered.Source(); err != ErrCommitmentMismatch {
+		t.Errorf("tampered seed source: got %v, want %v", err, ErrCommitmentMismatch)
+	}
+}
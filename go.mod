@@ -3,19 +3,14 @@ module github.com/travis-g/dice
 go 1.18
 
 require (
-	github.com/Knetic/govaluate v3.0.0+incompatible
-	github.com/alecthomas/participle/v2 v2.0.0-alpha9
-	github.com/alecthomas/repr v0.1.0
 	github.com/gorilla/mux v1.8.0
 	github.com/pkg/errors v0.9.1
 	github.com/ryanuber/columnize v2.1.2+incompatible
 	github.com/urfave/cli v1.22.5
-	go.uber.org/atomic v1.10.0
 	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
-	github.com/bradleyjkemp/memviz v0.2.3 // indirect
 	github.com/cpuguy83/go-md2man/v2 v2.0.0 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 )
@@ -0,0 +1,408 @@
+package dice
+
+// tokenKind is the kind of a lexical token within a dice expression.
+type tokenKind int
+
+// Token kinds.
+const (
+	tokenEOF tokenKind = iota
+	tokenIllegal
+	tokenNumber   // 1, 2.5
+	tokenNotation // 3d6, d20kh1, 4dF
+	tokenIdent    // floor, max
+	tokenVariable // @level
+	tokenRepeat   // 6x
+	tokenAdd      // +
+	tokenSub      // -
+	tokenMul      // *
+	tokenDiv      // /
+	tokenMod      // %
+	tokenPow      // ^ or **
+	tokenLParen   // (
+	tokenRParen   // )
+	tokenComma    // ,
+	tokenLBrace   // {
+	tokenRBrace   // }, }kh1
+	tokenLss      // <
+	tokenLeq      // <=
+	tokenGtr      // >
+	tokenGeq      // >=
+	tokenEql      // = or ==
+	tokenNeq      // !=
+	tokenQuestion // ?
+	tokenColon    // :
+	tokenLabel    // [fire]
+)
+
+var tokens = [...]string{
+	tokenEOF:      "end of expression",
+	tokenIllegal:  "illegal character",
+	tokenNumber:   "number",
+	tokenNotation: "dice notation",
+	tokenIdent:    "identifier",
+	tokenVariable: "variable",
+	tokenRepeat:   "repetition",
+	tokenAdd:      "+",
+	tokenSub:      "-",
+	tokenMul:      "*",
+	tokenDiv:      "/",
+	tokenMod:      "%",
+	tokenPow:      "^",
+	tokenLParen:   "(",
+	tokenRParen:   ")",
+	tokenComma:    ",",
+	tokenLBrace:   "{",
+	tokenRBrace:   "}",
+	tokenLss:      "<",
+	tokenLeq:      "<=",
+	tokenGtr:      ">",
+	tokenGeq:      ">=",
+	tokenEql:      "=",
+	tokenNeq:      "!=",
+	tokenQuestion: "?",
+	tokenColon:    ":",
+	tokenLabel:    "label",
+}
+
+func (k tokenKind) String() string {
+	if 0 <= k && k < tokenKind(len(tokens)) {
+		return tokens[k]
+	}
+	return "unknown token"
+}
+
+// A token is a lexical token of a dice expression along with the span of the
+// input it was scanned from. Notation tokens also carry the result of parsing
+// the notation.
+type token struct {
+	Span
+	kind  tokenKind
+	text  string
+	props RollerProperties
+	err   error
+}
+
+// A lexer splits a dice expression into tokens. Dice notations, including any
+// modifiers immediately following them, are scanned as a single token and
+// parsed by the notation parser.
+//
+// If comparisons is set, the success pool of a single die is instead scanned
+// as the die followed by a comparison, as described by comparisonSuffix. A
+// notation counted by a variable, like "@level d6>4", is not split.
+type lexer struct {
+	input       string
+	pos         int
+	comparisons bool
+	pending     *token
+	last        tokenKind
+}
+
+func newLexer(input string) *lexer {
+	return &lexer{input: input}
+}
+
+// next scans and returns the next token of the input.
+func (l *lexer) next() token {
+	if t := l.pending; t != nil {
+		l.pending = nil
+		return *t
+	}
+	for l.pos < len(l.input) && isSpace(l.input[l.pos]) {
+		l.pos++
+	}
+	start := l.pos
+	if start >= len(l.input) {
+		return token{Span: Span{start, start}, kind: tokenEOF}
+	}
+
+	// dice notations take precedence over numbers and identifiers, as both
+	// "3d6" and "d6" would otherwise be split.
+	if end := scanNotation(l.input, start); end > start {
+		return l.notation(end)
+	}
+
+	c := l.input[start]
+	switch {
+	case isDigit(c) || c == '.':
+		end := start
+		for end < len(l.input) && isDigit(l.input[end]) {
+			end++
+		}
+		// an integer immediately followed by an "x" is a repetition count
+		if c != '.' && end < len(l.input) && lower(l.input[end]) == 'x' {
+			return l.emit(tokenRepeat, end+1)
+		}
+		if end < len(l.input) && l.input[end] == '.' {
+			end++
+			for end < len(l.input) && isDigit(l.input[end]) {
+				end++
+			}
+		}
+		if end-start == 1 && c == '.' {
+			return l.emit(tokenIllegal, end)
+		}
+		return l.emit(tokenNumber, end)
+	case isLetter(c) || c == '_':
+		return l.emit(tokenIdent, scanIdent(l.input, start))
+	case c == '@':
+		if end := scanIdent(l.input, start+1); end > start+1 && !isDigit(l.input[start+1]) {
+			return l.emit(tokenVariable, end)
+		}
+		return l.emit(tokenIllegal, start+1)
+	}
+
+	switch c {
+	case '+':
+		return l.emit(tokenAdd, start+1)
+	case '-':
+		return l.emit(tokenSub, start+1)
+	case '*':
+		if l.peek(start+1) == '*' {
+			return l.emit(tokenPow, start+2)
+		}
+		return l.emit(tokenMul, start+1)
+	case '/':
+		return l.emit(tokenDiv, start+1)
+	case '%':
+		return l.emit(tokenMod, start+1)
+	case '^':
+		return l.emit(tokenPow, start+1)
+	case '(':
+		return l.emit(tokenLParen, start+1)
+	case ')':
+		return l.emit(tokenRParen, start+1)
+	case ',':
+		return l.emit(tokenComma, start+1)
+	case '<':
+		if l.peek(start+1) == '=' {
+			return l.emit(tokenLeq, start+2)
+		}
+		return l.emit(tokenLss, start+1)
+	case '>':
+		if l.peek(start+1) == '=' {
+			return l.emit(tokenGeq, start+2)
+		}
+		return l.emit(tokenGtr, start+1)
+	case '=':
+		if l.peek(start+1) == '=' {
+			return l.emit(tokenEql, start+2)
+		}
+		return l.emit(tokenEql, start+1)
+	case '!':
+		if l.peek(start+1) == '=' {
+			return l.emit(tokenNeq, start+2)
+		}
+	case '?':
+		return l.emit(tokenQuestion, start+1)
+	case ':':
+		return l.emit(tokenColon, start+1)
+	case '[':
+		return l.emit(tokenLabel, scanLabel(l.input, start))
+	case '{':
+		return l.emit(tokenLBrace, start+1)
+	case '}':
+		// modifiers immediately following a list apply to the list's items
+		end := start + 1
+		for end < len(l.input) && isModifierChar(l.input[end]) {
+			end++
+		}
+		return l.emit(tokenRBrace, end)
+	}
+	return l.emit(tokenIllegal, start+1)
+}
+
+// peek returns the byte of the input at offset i, or 0 past the end of the
+// input.
+func (l *lexer) peek(i int) byte {
+	if i < len(l.input) {
+		return l.input[i]
+	}
+	return 0
+}
+
+// emit returns a token of the given kind spanning from the lexer's position to
+// end, and advances the lexer past it.
+func (l *lexer) emit(kind tokenKind, end int) token {
+	t := token{
+		Span: Span{l.pos, end},
+		kind: kind,
+		text: l.input[l.pos:end],
+	}
+	l.pos = end
+	l.last = kind
+	return t
+}
+
+// notation returns a notation token spanning from the lexer's position to end,
+// along with its parsed properties, and advances the lexer past it. A single
+// die's success comparison split from the notation is held as the next token.
+func (l *lexer) notation(end int) token {
+	span := Span{l.pos, end}
+	props, err := parseNotation(l.input, span)
+	var op token
+	counted := l.last == tokenVariable && !isDigit(l.input[span.Start])
+	if l.comparisons && err == nil && !counted {
+		if at, ok := comparisonSuffix(l.input, span, props); ok {
+			mods := props.GroupModifiers
+			target := mods[len(mods)-1].(*SuccessModifier).CompareTarget
+			props.GroupModifiers = mods[:len(mods)-1]
+			op = comparisonToken(l.input, at, target.Compare)
+			end = at
+		}
+	}
+	t := l.emit(tokenNotation, end)
+	t.props, t.err = props, err
+	if op.kind != tokenEOF {
+		l.pending = &op
+		l.pos = op.End
+	}
+	return t
+}
+
+// comparisonSuffix returns the start of the trailing success comparison of the
+// notation at span within input, if the notation rolls a single die and its
+// only pool modifier is that comparison. The die then counts as a success, 1,
+// exactly when comparing it with the comparison's inclusive operator passes,
+// so "d20>15" is read as the comparison "d20 >= 15" and has an outcome.
+// Notations of multiple dice, like "3d6>4", remain success pools.
+func comparisonSuffix(input string, span Span, props RollerProperties) (int, bool) {
+	mods := props.GroupModifiers
+	if props.Count != 1 || len(mods) == 0 {
+		return 0, false
+	}
+	if _, ok := mods[len(mods)-1].(*SuccessModifier); !ok {
+		return 0, false
+	}
+	for _, mod := range mods[:len(mods)-1] {
+		switch mod.(type) {
+		case *SuccessModifier, *FailureModifier:
+			return 0, false
+		}
+	}
+	at := span.End
+	for at > span.Start && isDigit(input[at-1]) {
+		at--
+	}
+	if at > span.Start && input[at-1] == '=' {
+		at--
+	}
+	if at > span.Start && (input[at-1] == '<' || input[at-1] == '>') {
+		at--
+	}
+	return at, at > span.Start
+}
+
+// comparisonToken returns the token of a success pool's comparison operator
+// starting at start within s. Success pools compare inclusively, so "<" and
+// ">" are scanned as "<=" and ">=".
+func comparisonToken(s string, start int, op CompareOp) token {
+	end := start + 1
+	if s[start] != '=' && end < len(s) && s[end] == '=' {
+		end++
+	}
+	kind := tokenEql
+	switch op {
+	case LSS, LEQ:
+		kind = tokenLeq
+	case GTR, GEQ:
+		kind = tokenGeq
+	}
+	return token{Span: Span{start, end}, kind: kind, text: s[start:end]}
+}
+
+// scanNotation returns the end offset of a dice notation starting at start
+// within s, or start if there is no notation there. A notation is an optional
+// count, a "d", a size with optional braced weights, "F", "%", or a braced
+// list of faces, and any modifier characters that follow.
+func scanNotation(s string, start int) int {
+	i := start
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	if i >= len(s) || (s[i] != 'd' && s[i] != 'D') {
+		return start
+	}
+	i++
+	switch {
+	case i < len(s) && isDigit(s[i]):
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+		if i < len(s) && s[i] == '{' {
+			i = scanBraces(s, i)
+		}
+	case i < len(s) && (s[i] == 'f' || s[i] == 'F' || s[i] == '%'):
+		i++
+	case i < len(s) && s[i] == '{':
+		i = scanBraces(s, i)
+	default:
+		return start
+	}
+	for i < len(s) && isModifierChar(s[i]) {
+		i++
+	}
+	return i
+}
+
+// scanIdent returns the end offset of an identifier starting at start within s.
+func scanIdent(s string, start int) int {
+	end := start
+	for end < len(s) && (isLetter(s[end]) || isDigit(s[end]) || s[end] == '_') {
+		end++
+	}
+	return end
+}
+
+// scanBraces returns the end offset of a braced list starting at start within
+// s. An unclosed list runs to the end of s, and is left for the parser to
+// report.
+func scanBraces(s string, start int) int {
+	i := start
+	for i < len(s) && s[i] != '}' {
+		i++
+	}
+	if i < len(s) {
+		i++
+	}
+	return i
+}
+
+// scanLabel returns the end offset of a bracketed label starting at start
+// within s. An unclosed label runs to the end of s, and is left for the parser
+// to report.
+func scanLabel(s string, start int) int {
+	i := start
+	for i < len(s) && s[i] != ']' {
+		i++
+	}
+	if i < len(s) {
+		i++
+	}
+	return i
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// isModifierChar returns whether c may appear within a dice notation's
+// modifier string.
+func isModifierChar(c byte) bool {
+	return isLetter(c) || isDigit(c) || c == '!' || c == '=' || c == '<' || c == '>'
+}
+
+// lower returns the lowercase form of an ASCII letter.
+func lower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + ('a' - 'A')
+	}
+	return c
+}
@@ -11,8 +11,8 @@ operations:
 	Multiplication, division, and modulus from left to right,
 	Addition and subtraction from left to right
 
-The math package currently relies heavily on
-https://github.com/Knetic/govaluate.
+Expressions are parsed into an abstract syntax tree by the dice package's
+ParseExpression, which Evaluate then walks to roll dice and compute a result.
 
 # Benchmarks
 
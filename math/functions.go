@@ -4,8 +4,6 @@ import (
 	"errors"
 	"math"
 	"sort"
-
-	eval "github.com/Knetic/govaluate"
 )
 
 // Possible error types for mathematical functions.
@@ -14,9 +12,14 @@ var (
 	ErrInvalidArgCount = errors.New("invalid argument count")
 )
 
+// An ExpressionFunction is a function that can be called from within a dice
+// expression. Arguments are passed as float64s and the function must return a
+// float64.
+type ExpressionFunction func(args ...interface{}) (interface{}, error)
+
 // DiceFunctions are functions usable in dice arithmetic operations, such as
 // round, min, and max.
-var DiceFunctions = map[string]eval.ExpressionFunction{
+var DiceFunctions = map[string]ExpressionFunction{
 	"abs":   absExpressionFunction,
 	"ceil":  ceilExpressionFunction,
 	"floor": floorExpressionFunction,
@@ -5,10 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
-	eval "github.com/Knetic/govaluate"
 	"github.com/travis-g/dice"
 )
 
@@ -28,6 +28,60 @@ type ExpressionResult struct {
 	// Dice is the list of dice groups rolled as part of the expression. As dice
 	// are rolled, their GroupProperties are retrieved.
 	Dice []*dice.RollerGroup `json:"dice,omitempty"`
+
+	// CritSuccess and CritFailure are whether any die kept within the
+	// expression rolled a critical success or critical failure respectively.
+	// Only the dice of d20-style rolls, like d20+5 or 2d20kh1, and of dice with
+	// explicit critical modifiers, like 3d6cs>5, count.
+	CritSuccess bool `json:"crit,omitempty"`
+	CritFailure bool `json:"fumble,omitempty"`
+
+	// Labels are the subtotals of the expression's labeled operands, such as
+	// the 1d8 of 1d8[slashing], by label. The subtotal of a label is the sum
+	// of what the operands with that label add to the result, so operands
+	// that are subtracted or negated count against it and multiplied or
+	// divided operands count as scaled. Evaluating an expression whose
+	// labeled operands have no such share, such as the divisor of a quotient
+	// or the argument of a function, returns an error.
+	Labels map[string]float64 `json:"labels,omitempty"`
+
+	// Outcome is the outcome of the expression's comparison, if the
+	// expression is a comparison like d20+5 >= 15, or a conditional whose
+	// condition is, like d20 >= 15 ? 2d6 : 0.
+	Outcome *CompareOutcome `json:"outcome,omitempty"`
+
+	// Results are the separate rolls of a repeated roll or roll list, such as
+	// 6x 4d6kh3 or {d20+5, d20+5}. If there are Results, Result is their sum
+	// and Dice are the dice rolled by all of them.
+	Results []*ExpressionResult `json:"results,omitempty"`
+}
+
+// A CompareOutcome is the outcome of a comparison, such as an attack roll
+// against an armor class or a saving throw against a difficulty class.
+type CompareOutcome struct {
+	// Pass is whether the comparison holds.
+	Pass bool `json:"pass"`
+
+	// Total is the value of the comparison's left side, like the attack
+	// roll's total of d20+5 >= 15.
+	Total float64 `json:"total"`
+
+	// Margin is the difference between the comparison's left and right
+	// sides, left minus right.
+	Margin float64 `json:"margin"`
+}
+
+// String implements fmt.Stringer.
+func (o *CompareOutcome) String() string {
+	if o == nil {
+		return ""
+	}
+	s := "fail"
+	if o.Pass {
+		s = "pass"
+	}
+	return s + ", total " + strconv.FormatFloat(o.Total, 'f', -1, 64) +
+		", margin " + strconv.FormatFloat(o.Margin, 'f', -1, 64)
 }
 
 // String implements fmt.Stringer.
@@ -35,8 +89,29 @@ func (de *ExpressionResult) String() string {
 	if de == nil {
 		return ""
 	}
+	if len(de.Results) > 0 {
+		results := make([]string, len(de.Results))
+		for i, r := range de.Results {
+			results[i] = r.String()
+		}
+		return strings.Join(results, "\n")
+	}
 	// as there could be a float/decimal result, format the float properly
-	return fmt.Sprintf("%s = %s", de.Rolled, strconv.FormatFloat(de.Result, 'f', -1, 64))
+	s := fmt.Sprintf("%s = %s", de.Rolled, strconv.FormatFloat(de.Result, 'f', -1, 64))
+	var notes []string
+	if de.Outcome != nil {
+		notes = append(notes, de.Outcome.String())
+	}
+	if de.CritSuccess {
+		notes = append(notes, "critical success")
+	}
+	if de.CritFailure {
+		notes = append(notes, "critical failure")
+	}
+	if len(notes) > 0 {
+		s += " (" + strings.Join(notes, ", ") + ")"
+	}
+	return s
 }
 
 // GoString implements fmt.GoStringer.
@@ -58,90 +133,481 @@ could be a simple expression or more complex.
 	min(d20,d20)+1
 	floor(max(d20,2d12k1)/2+3)
 
-EvaluateExpression can likely benefit immensely from optimization and a custom parser
-implementation along with more fine-grained unit tests/benchmarks.
+An expression can end with a comparison, or be a conditional, in which case
+the ExpressionResult's Outcome records whether the comparison passed. A
+comparison evaluates to 1 if it holds and 0 otherwise, and must be separated
+from a preceding dice notation by whitespace, as it would otherwise be read as
+a modifier of the notation.
+
+	d20+5 >= 15
+	d20 >= 15 ? 2d6+3 : 0
+
+An expression can also be repeated, or be a braced list of expressions, to
+make several separate rolls at once. Each roll's result is among the returned
+ExpressionResult's Results.
+
+	6x 4d6kh3
+	{d20+5, d20+5, d12+3}
+
+Dice and other operands can be labeled, such as with damage types. The
+ExpressionResult's Labels are the subtotals of each label's operands.
+
+	1d8[slashing] + 2d6[fire] + 3[slashing]
+
+The expression is parsed into an abstract syntax tree with
+dice.ParseExpression, which is then walked and evaluated directly.
 */
 func EvaluateExpression(ctx context.Context, expression string) (*ExpressionResult, error) {
+	node, err := dice.ParseExpression(ctx, expression)
+	if err != nil {
+		return nil, err
+	}
+	return Evaluate(ctx, expression, node)
+}
+
+// Evaluate evaluates a parsed dice expression's syntax tree, rolling any dice
+// within it. The expression the tree was parsed from is used to construct the
+// result's Original and Rolled fields.
+func Evaluate(ctx context.Context, expression string, node dice.Node) (*ExpressionResult, error) {
+	if node == nil {
+		return nil, ErrNilExpression
+	}
+	var rolls []dice.Node
+	switch n := node.(type) {
+	case *dice.RepeatNode:
+		for i := 0; i < n.Count; i++ {
+			rolls = append(rolls, n.X)
+		}
+	case *dice.ListNode:
+		rolls = n.Items
+	}
+	if len(rolls) == 0 || isGrouped(node) {
+		return evaluate(ctx, expression, dice.Span{Start: 0, End: len(expression)}, node)
+	}
+
 	de := &ExpressionResult{
 		Original: expression,
 		Dice:     make([]*dice.RollerGroup, 0),
+		Results:  make([]*ExpressionResult, 0, len(rolls)),
+	}
+	rolled := make([]string, len(rolls))
+	for i, roll := range rolls {
+		r, err := evaluate(ctx, expression, roll.Pos(), roll)
+		if err != nil {
+			return nil, err
+		}
+		de.Results = append(de.Results, r)
+		de.Result += r.Result
+		de.Dice = append(de.Dice, r.Dice...)
+		de.CritSuccess = de.CritSuccess || r.CritSuccess
+		de.CritFailure = de.CritFailure || r.CritFailure
+		de.addLabels(r.Labels)
+		rolled[i] = r.Rolled
+	}
+	de.Rolled = "{" + strings.Join(rolled, ", ") + "}"
+	return de, nil
+}
+
+// addLabels adds label subtotals to the result's.
+func (de *ExpressionResult) addLabels(labels map[string]float64) {
+	de.addScaledLabels(labels, 1)
+}
+
+// addScaledLabels adds label subtotals, multiplied by factor, to the result's.
+func (de *ExpressionResult) addScaledLabels(labels map[string]float64, factor float64) {
+	for label, v := range labels {
+		if de.Labels == nil {
+			de.Labels = make(map[string]float64, len(labels))
+		}
+		de.Labels[label] += v * factor
 	}
+}
+
+// isGrouped returns whether a node is a roll list with modifiers, which is
+// rolled as a single group rather than as separate rolls.
+func isGrouped(node dice.Node) bool {
+	list, ok := node.(*dice.ListNode)
+	return ok && len(list.Modifiers) > 0
+}
 
-	var evalErrors = []error{}
+// evaluate evaluates a single roll, the node of which was parsed from the span
+// of the expression.
+func evaluate(ctx context.Context, expression string, span dice.Span, node dice.Node) (*ExpressionResult, error) {
+	e := &evaluator{
+		ctx:     ctx,
+		input:   expression,
+		outcome: outcomeComparison(node),
+		de: &ExpressionResult{
+			Original: expression[span.Start:span.End],
+			Dice:     make([]*dice.RollerGroup, 0),
+		},
+	}
+	result, err := e.eval(node)
+	if err != nil {
+		return nil, err
+	}
+	e.de.Result = result
+	e.de.Rolled = e.rolled(span)
+	return e.de, nil
+}
 
-	// systematically parse the DiceExpression for dice notation substrings,
-	// evaluate and expand the rolls, replace the notation strings with their
-	// fully-rolled and expanded counterparts, and save the expanded expression
-	// to the object.
-	rolledBytes := dice.DiceWithModifiersExpressionRegex.ReplaceAllFunc([]byte(de.Original), func(matchBytes []byte) []byte {
-		// check for context expiry
-		select {
+// outcomeComparison returns the comparison that determines the outcome of a
+// roll, if any: the roll itself or the condition of a conditional roll.
+func outcomeComparison(node dice.Node) *dice.BinaryNode {
+	for {
+		switch n := node.(type) {
+		case *dice.ParenNode:
+			node = n.X
+		case *dice.ConditionalNode:
+			node = n.Cond
+		case *dice.BinaryNode:
+			if n.Op.IsComparison() {
+				return n
+			}
+			return nil
 		default:
-		case <-ctx.Done():
-			panic(ctx.Err())
+			return nil
 		}
-		props, err := dice.ParseNotation(ctx, string(matchBytes))
+	}
+}
+
+// A replacement is a span of the original expression to replace with text
+// when rendering the rolled expression.
+type replacement struct {
+	dice.Span
+	text string
+}
+
+// An evaluator walks and evaluates an expression's syntax tree.
+type evaluator struct {
+	ctx          context.Context
+	input        string
+	outcome      *dice.BinaryNode
+	de           *ExpressionResult
+	replacements []replacement
+}
+
+func (e *evaluator) eval(node dice.Node) (float64, error) {
+	// check for context expiry
+	if err := e.ctx.Err(); err != nil {
+		return 0, err
+	}
+	switch n := node.(type) {
+	case *dice.NumberNode:
+		return n.Value, nil
+	case *dice.NotationNode:
+		return e.evalNotation(n)
+	case *dice.VariableNode:
+		v, err := n.Value(e.ctx)
 		if err != nil {
-			evalErrors = append(evalErrors, err)
-			return []byte{}
+			return 0, err
 		}
-		d, err := dice.NewRollerGroup(&props)
+		e.replacements = append(e.replacements, replacement{n.Span, strconv.FormatFloat(v, 'f', -1, 64)})
+		return v, nil
+	case *dice.ParenNode:
+		return e.eval(n.X)
+	case *dice.LabelNode:
+		v, err := e.eval(n.X)
 		if err != nil {
-			evalErrors = append(evalErrors, err)
-			return []byte{}
+			return 0, err
+		}
+		e.de.addLabels(map[string]float64{n.Label: v})
+		return v, nil
+	case *dice.ListNode:
+		if len(n.Modifiers) > 0 {
+			return e.evalGroupedList(n)
+		}
+		var sum float64
+		for _, item := range n.Items {
+			v, err := e.eval(item)
+			if err != nil {
+				return 0, err
+			}
+			sum += v
 		}
-		err = d.FullRoll(ctx)
+		return sum, nil
+	case *dice.UnaryNode:
+		x, labels, err := e.evalLabels(n.X)
 		if err != nil {
-			evalErrors = append(evalErrors, err)
-			return []byte{}
-		}
-		// record dice:
-		de.Dice = append(de.Dice, d)
-
-		// write expanded result back as bytes
-		var b strings.Builder
-		write := b.WriteString
-		write(`(`)
-		write(d.Expression())
-		write(`)`)
-		return []byte(b.String())
-	})
-	if len(evalErrors) != 0 {
-		return nil, fmt.Errorf("errors during evaluation: %v", evalErrors)
+			return 0, err
+		}
+		switch n.Op {
+		case dice.OpAdd:
+			e.de.addLabels(labels)
+			return x, nil
+		case dice.OpSub:
+			e.de.addScaledLabels(labels, -1)
+			return -x, nil
+		}
+		return 0, fmt.Errorf("unknown unary operator %q", n.Op)
+	case *dice.BinaryNode:
+		left, leftLabels, err := e.evalLabels(n.Left)
+		if err != nil {
+			return 0, err
+		}
+		right, rightLabels, err := e.evalLabels(n.Right)
+		if err != nil {
+			return 0, err
+		}
+		v, err := n.Op.Eval(left, right)
+		if err != nil {
+			return 0, err
+		}
+		// scale each side's labels by what it contributes to the result
+		leftFactor, rightFactor := 1.0, 1.0
+		switch n.Op {
+		case dice.OpSub:
+			rightFactor = -1
+		case dice.OpMul:
+			if len(leftLabels) > 0 && len(rightLabels) > 0 {
+				return 0, e.errorAt(n, ErrLabeledOperand)
+			}
+			leftFactor, rightFactor = right, left
+		case dice.OpDiv:
+			if len(rightLabels) > 0 {
+				return 0, e.errorAt(n.Right, ErrLabeledDivisor)
+			}
+			leftFactor = 1 / right
+		case dice.OpPow, dice.OpMod:
+			if len(leftLabels) > 0 {
+				return 0, e.errorAt(n.Left, ErrLabeledOperand)
+			}
+			if len(rightLabels) > 0 {
+				return 0, e.errorAt(n.Right, ErrLabeledOperand)
+			}
+		}
+		e.de.addScaledLabels(leftLabels, leftFactor)
+		e.de.addScaledLabels(rightLabels, rightFactor)
+		if n == e.outcome {
+			e.de.Outcome = &CompareOutcome{Pass: v != 0, Total: left, Margin: left - right}
+		}
+		return v, nil
+	case *dice.ConditionalNode:
+		// only the chosen branch is rolled
+		cond, err := e.eval(n.Cond)
+		if err != nil {
+			return 0, err
+		}
+		if cond != 0 {
+			return e.eval(n.Then)
+		}
+		return e.eval(n.Else)
+	case *dice.CallNode:
+		return e.evalCall(n)
 	}
-	de.Rolled = string(rolledBytes)
+	return 0, dice.ErrInvalidExpression
+}
+
+// evalLabels evaluates a node and returns the label subtotals of its operands
+// separately from the result's, so that they can be scaled.
+func (e *evaluator) evalLabels(node dice.Node) (float64, map[string]float64, error) {
+	labels := e.de.Labels
+	e.de.Labels = nil
+	v, err := e.eval(node)
+	scoped := e.de.Labels
+	e.de.Labels = labels
+	return v, scoped, err
+}
 
-	// populate the expression object with the roll and function data
-	exp, err := eval.NewEvaluableExpressionWithFunctions(de.Rolled, DiceFunctions)
+// evalNotation creates and rolls the dice group described by a notation, and
+// records the group and its rolled expression.
+func (e *evaluator) evalNotation(n *dice.NotationNode) (float64, error) {
+	// copy the properties, as creating dice may modify them
+	props, err := n.Resolve(e.ctx)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	if exp == nil {
-		return nil, ErrNilExpression
+	for _, face := range props.Faces {
+		if face.Symbol != "" {
+			return 0, fmt.Errorf("%s: %w", e.input[n.Start:n.End], ErrNonNumeric)
+		}
+	}
+	d, err := dice.NewRollerGroup(&props)
+	if err != nil {
+		return 0, err
+	}
+	if err = d.FullRoll(e.ctx); err != nil {
+		return 0, err
+	}
+	// record dice:
+	e.de.Dice = append(e.de.Dice, d)
+	if surfacesCriticals(e.ctx, &props, d) {
+		for _, r := range d.Group {
+			if die, ok := r.(*dice.Die); ok && die.Result != nil && !die.IsDropped(e.ctx) {
+				e.de.CritSuccess = e.de.CritSuccess || die.CritSuccess
+				e.de.CritFailure = e.de.CritFailure || die.CritFailure
+			}
+		}
+	}
+
+	// write expanded result back
+	var b strings.Builder
+	write := b.WriteString
+	write(`(`)
+	write(d.Expression())
+	write(`)`)
+	e.replacements = append(e.replacements, replacement{n.Span, b.String()})
+	return d.Total(e.ctx)
+}
+
+// surfacesCriticals returns whether the criticals of a rolled group count
+// towards the expression's. Every die rolls criticals on its highest and
+// lowest faces by default, which is only meaningful for a d20-style roll of a
+// single kept d20, like d20 or 2d20kh1; other groups only count if they set
+// their criticals explicitly, like 3d6cs>5.
+func surfacesCriticals(ctx context.Context, props *dice.RollerProperties, d *dice.RollerGroup) bool {
+	for _, mod := range props.DieModifiers {
+		switch mod.(type) {
+		case *dice.CriticalSuccessModifier, *dice.CriticalFailureModifier:
+			return true
+		}
+	}
+	if props.Type != dice.TypePolyhedron || props.Size != 20 {
+		return false
+	}
+	var kept int
+	for _, r := range d.Group {
+		if !r.IsDropped(ctx) {
+			kept++
+		}
+	}
+	return kept == 1
+}
+
+// evalGroupedList evaluates a roll list with modifiers. Each of the list's
+// items is ranked by its total, as if it were a die of a group, and only the
+// kept items are included in the list's total and rolled expression.
+func (e *evaluator) evalGroupedList(n *dice.ListNode) (float64, error) {
+	type item struct {
+		rolled                   string
+		critSuccess, critFailure bool
+		labels                   map[string]float64
+	}
+	items := make(map[dice.Roller]item, len(n.Items))
+	group := &dice.RollerGroup{
+		Group:     make(dice.Group, 0, len(n.Items)),
+		Modifiers: n.Modifiers,
+	}
+	critSuccess, critFailure, labels := e.de.CritSuccess, e.de.CritFailure, e.de.Labels
+	for _, x := range n.Items {
+		// only the criticals and labels of kept items count, so track each
+		// item's
+		e.de.CritSuccess, e.de.CritFailure, e.de.Labels = false, false, nil
+		mark := len(e.replacements)
+		v, err := e.eval(x)
+		if err != nil {
+			return 0, err
+		}
+		die := &dice.Die{Result: &dice.Result{Value: v}}
+		items[die] = item{
+			rolled:      e.render(x.Pos(), e.replacements[mark:]),
+			critSuccess: e.de.CritSuccess,
+			critFailure: e.de.CritFailure,
+			labels:      e.de.Labels,
+		}
+		e.replacements = e.replacements[:mark]
+		group.Group = append(group.Group, die)
+	}
+	e.de.CritSuccess, e.de.CritFailure, e.de.Labels = critSuccess, critFailure, labels
+
+	for _, mod := range n.Modifiers {
+		if err := mod.Apply(e.ctx, group); err != nil {
+			return 0, err
+		}
 	}
+	kept := make([]string, 0, len(group.Group))
+	for _, r := range group.Group {
+		if r.IsDropped(e.ctx) {
+			continue
+		}
+		it := items[r]
+		kept = append(kept, it.rolled)
+		e.de.CritSuccess = e.de.CritSuccess || it.critSuccess
+		e.de.CritFailure = e.de.CritFailure || it.critFailure
+		e.de.addLabels(it.labels)
+	}
+	e.replacements = append(e.replacements, replacement{n.Span, "{" + strings.Join(kept, ", ") + "}"})
+	return group.Total(e.ctx)
+}
 
-	// get and set the result
-	result, err := exp.Evaluate(nil)
+func (e *evaluator) evalCall(n *dice.CallNode) (float64, error) {
+	f, ok := DiceFunctions[n.Func]
+	if !ok {
+		return 0, fmt.Errorf("unknown function %q", n.Func)
+	}
+	args := make([]interface{}, len(n.Args))
+	for i, arg := range n.Args {
+		v, labels, err := e.evalLabels(arg)
+		if err != nil {
+			return 0, err
+		}
+		if len(labels) > 0 {
+			return 0, e.errorAt(arg, ErrLabeledOperand)
+		}
+		args[i] = v
+	}
+	result, err := f(args...)
 	if err != nil {
-		return nil, dice.ErrInvalidExpression
+		return 0, err
 	}
 	if result == nil {
-		return de, ErrNilResult
+		return 0, ErrNilResult
 	}
-
 	// result should be a float
-	var ok bool
-	if de.Result, ok = result.(float64); !ok {
-		return de, fmt.Errorf("result %v not a float", err)
+	v, ok := result.(float64)
+	if !ok {
+		return 0, fmt.Errorf("result %v not a float", result)
 	}
+	return v, nil
+}
 
-	return de, nil
+// errorAt returns err prefixed with the part of the input expression that node
+// was parsed from.
+func (e *evaluator) errorAt(node dice.Node, err error) error {
+	span := node.Pos()
+	return fmt.Errorf("%s: %w", e.input[span.Start:span.End], err)
+}
+
+// rolled returns the span of the input expression with the evaluator's
+// replacements applied.
+func (e *evaluator) rolled(span dice.Span) string {
+	return e.render(span, e.replacements)
+}
+
+// render returns the span of the input expression with replacements, which
+// must lie within the span, applied.
+func (e *evaluator) render(span dice.Span, replacements []replacement) string {
+	sort.SliceStable(replacements, func(i, j int) bool {
+		return replacements[i].Start < replacements[j].Start
+	})
+	var b strings.Builder
+	last := span.Start
+	for _, r := range replacements {
+		b.WriteString(e.input[last:r.Start])
+		b.WriteString(r.text)
+		last = r.End
+	}
+	b.WriteString(e.input[last:span.End])
+	return b.String()
 }
 
 // Math package errors.
 var (
 	ErrNilExpression = errors.New("nil expression")
 	ErrNilResult     = errors.New("nil result")
+
+	// ErrNonNumeric is returned when an expression contains dice with symbol
+	// faces, which have no numeric value.
+	ErrNonNumeric = errors.New("dice with symbol faces cannot be totaled")
+
+	// ErrLabeledDivisor is returned when a divisor contains labeled operands,
+	// which have no share of the quotient to be subtotaled.
+	ErrLabeledDivisor = errors.New("labeled operands cannot be divisors")
+
+	// ErrLabeledOperand is returned when labeled operands are multiplied by
+	// other labeled operands, are operands of "^" or "%", or are arguments of a
+	// function, which have no share of the result to be subtotaled.
+	ErrLabeledOperand = errors.New("labeled operands cannot be subtotaled")
 )
 
 // ParseExpressionWithFunc
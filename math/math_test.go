@@ -2,8 +2,13 @@ package math
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/travis-g/dice"
@@ -12,7 +17,7 @@ import (
 // package-level variable to prevent optimizations
 var (
 	i   interface{}
-	ctx = context.Background()
+	ctx context.Context
 )
 
 // Check implements
@@ -21,14 +26,14 @@ var (
 	_ = fmt.GoStringer(&ExpressionResult{})
 )
 
-// function to set math/rand as the RNG source
-func sourceMathRand() {
+// function to set math/rand as the context's RNG source
+func sourceMathRand() context.Context {
 	seed, _ := dice.CryptoInt64()
-	dice.Source = rand.New(rand.NewSource(seed))
+	return context.WithValue(context.Background(), dice.CtxKeySource, rand.New(rand.NewSource(seed)))
 }
 
 func init() {
-	sourceMathRand()
+	ctx = sourceMathRand()
 }
 
 func BenchmarkEvaluate(b *testing.B) {
@@ -144,6 +149,18 @@ func TestEvaluate(t *testing.T) {
 		{"1", 1},
 		{"d1", 1},
 		{"d0", 0},
+		{"0d6", 0},
+		{"1+2*3", 7},
+		{"(1+2)*3", 9},
+		{"-d1+3", 2},
+		{"2^3", 8},
+		{"2**3**2", 512},
+		{"7%4", 3},
+		{"3d1kh2", 2},
+		{"max(d1, 2d1) * 2", 4},
+		{"3d{2} + 1", 7},
+		{"d{-1.5,-1.5}*2", -3},
+		{"d%*0 + 5 % 3", 2},
 	}
 	var de *ExpressionResult
 	for _, tc := range testCases {
@@ -158,3 +175,486 @@ func TestEvaluate(t *testing.T) {
 	}
 	i = de
 }
+
+func TestEvaluate_Rolled(t *testing.T) {
+	testCases := []struct {
+		expression string
+		rolled     string
+	}{
+		{"1 + 2", "1 + 2"},
+		{"d1", "(1)"},
+		{"3d1 + 1", "(1+1+1) + 1"},
+		{"max(d1, 2)", "max((1), 2)"},
+		{"2d{ 4 }+1", "(4+4)+1"},
+	}
+	for _, tc := range testCases {
+		de, err := EvaluateExpression(ctx, tc.expression)
+		if err != nil {
+			t.Fatalf("error evaluating \"%s\": %s", tc.expression, err)
+		}
+		if de.Rolled != tc.rolled {
+			t.Errorf("evaluated %s; got rolled %q, wanted %q", tc.expression, de.Rolled, tc.rolled)
+		}
+	}
+}
+
+func TestEvaluate_RolledHistory(t *testing.T) {
+	// every 1 is rerolled, so any history is a chain of 1s ending in a 2
+	rolled := regexp.MustCompile(`^\(((\[(1→)+2\]|2)\+?){10}\)$`)
+	for i := 0; i < 20; i++ {
+		de, err := EvaluateExpression(ctx, "10d2r1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rolled.MatchString(de.Rolled) {
+			t.Errorf("got rolled %q, wanted rerolled dice rendered with history", de.Rolled)
+		}
+	}
+}
+
+func TestEvaluate_Criticals(t *testing.T) {
+	testCases := []struct {
+		expression  string
+		critSuccess bool
+		critFailure bool
+		str         string
+	}{
+		{"d1 + 1", false, false, "(1) + 1 = 2"},
+		{"3d1 + d1cs>1", true, true, "(1+1+1) + (1) = 4 (critical success, critical failure)"},
+		{"d1cs>2cf<0", false, false, "(1) = 1"},
+		{"d1cf>2", true, false, "(1) = 1 (critical success)"},
+		{"2d1cs>2cf<0kh1 + d1cs>2cf<0", false, false, "(1) + (1) = 2"},
+		{"1 + 2", false, false, "1 + 2 = 3"},
+		{"d20cs>1cf<0 + 3d6", true, false, ""},
+		{"3d20cs>1cf<0kh1", true, false, ""},
+	}
+	for _, tc := range testCases {
+		de, err := EvaluateExpression(ctx, tc.expression)
+		if err != nil {
+			t.Fatalf("error evaluating \"%s\": %s", tc.expression, err)
+		}
+		if de.CritSuccess != tc.critSuccess || de.CritFailure != tc.critFailure {
+			t.Errorf("evaluated %s; got crit success %v, crit failure %v, wanted %v, %v", tc.expression,
+				de.CritSuccess, de.CritFailure, tc.critSuccess, tc.critFailure)
+		}
+		if tc.str != "" && de.String() != tc.str {
+			t.Errorf("evaluated %s; got %q, wanted %q", tc.expression, de.String(), tc.str)
+		}
+	}
+
+	// only d20-style rolls count their default criticals
+	for i := 0; i < 200; i++ {
+		for _, tc := range []struct {
+			expression string
+			crits      bool
+		}{
+			{"d20 + 5", true},
+			{"2d20kh1", true},
+			{"3d6", false},
+			{"2d20", false},
+			{"d20 >= 15 ? 2d6 : 0", true},
+		} {
+			de, err := EvaluateExpression(ctx, tc.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			crit := tc.crits && strings.HasPrefix(de.Rolled, "(20)")
+			fumble := tc.crits && strings.HasPrefix(de.Rolled, "(1)")
+			if de.CritSuccess != crit || de.CritFailure != fumble {
+				t.Fatalf("evaluated %s; got %q with crit success %v, crit failure %v, wanted %v, %v",
+					tc.expression, de.Rolled, de.CritSuccess, de.CritFailure, crit, fumble)
+			}
+		}
+	}
+}
+
+func TestEvaluate_Errors(t *testing.T) {
+	testCases := []string{
+		"",
+		"d20+",
+		"unknown(1)",
+		"floor(1, 2)",
+		"d{1,blank}",
+	}
+	for _, expression := range testCases {
+		if _, err := EvaluateExpression(ctx, expression); err == nil {
+			t.Errorf("evaluated %q; wanted error", expression)
+		}
+	}
+}
+
+func TestEvaluate_NonNumeric(t *testing.T) {
+	_, err := EvaluateExpression(ctx, "1 + 2d{+,-,blank}")
+	if !errors.Is(err, ErrNonNumeric) {
+		t.Fatalf("got error %v, wanted %v", err, ErrNonNumeric)
+	}
+	if want := "2d{+,-,blank}: "; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("got error %q, wanted it to name the notation %q", err, want)
+	}
+}
+
+func TestEvaluate_Variables(t *testing.T) {
+	ctx := context.WithValue(ctx, dice.CtxKeyParameters, map[string]interface{}{
+		"str_mod": 3,
+		"prof":    json.Number("2"),
+		"level":   int64(4),
+		"half":    0.5,
+	})
+	testCases := []struct {
+		expression string
+		result     float64
+		rolled     string
+	}{
+		{"d1 + @str_mod + @prof", 6, "(1) + 3 + 2"},
+		{"@level d1 + 1", 5, "(1+1+1+1) + 1"},
+		{"max(@level, @str_mod)*@half", 2, "max(4, 3)*0.5"},
+	}
+	for _, tc := range testCases {
+		de, err := EvaluateExpression(ctx, tc.expression)
+		if err != nil {
+			t.Fatalf("error evaluating \"%s\": %s", tc.expression, err)
+		}
+		if de.Result != tc.result || de.Rolled != tc.rolled {
+			t.Errorf("evaluated %s; got %v %q, wanted %v %q", tc.expression,
+				de.Result, de.Rolled, tc.result, tc.rolled)
+		}
+	}
+
+	_, err := EvaluateExpression(ctx, "d20 + @dex_mod")
+	var unbound *dice.ErrUnboundVariable
+	if !errors.As(err, &unbound) || unbound.Name != "dex_mod" {
+		t.Fatalf("got error %v, wanted unbound variable @dex_mod", err)
+	}
+	for _, expression := range []string{"@half d6", "@missing d6"} {
+		if _, err := EvaluateExpression(ctx, expression); err == nil {
+			t.Errorf("evaluated %q; wanted error", expression)
+		}
+	}
+}
+
+func TestEvaluate_Results(t *testing.T) {
+	testCases := []struct {
+		expression string
+		originals  []string
+		result     float64
+		rolled     string
+	}{
+		{"3x 2d1+1", []string{"2d1+1", "2d1+1", "2d1+1"}, 9, "{(1+1)+1, (1+1)+1, (1+1)+1}"},
+		{"{d1+5, 3d1 , 2}", []string{"d1+5", "3d1", "2"}, 11, "{(1)+5, (1+1+1), 2}"},
+		{"{d1, {d1, 2}}", []string{"d1", "{d1, 2}"}, 4, "{(1), {(1), 2}}"},
+	}
+	for _, tc := range testCases {
+		de, err := EvaluateExpression(ctx, tc.expression)
+		if err != nil {
+			t.Fatalf("error evaluating \"%s\": %s", tc.expression, err)
+		}
+		if len(de.Results) != len(tc.originals) {
+			t.Fatalf("evaluated %s; got %d results, wanted %d", tc.expression, len(de.Results), len(tc.originals))
+		}
+		for i, r := range de.Results {
+			if r.Original != tc.originals[i] {
+				t.Errorf("evaluated %s; got result %d of %q, wanted %q", tc.expression, i, r.Original, tc.originals[i])
+			}
+		}
+		if de.Result != tc.result || de.Rolled != tc.rolled {
+			t.Errorf("evaluated %s; got %v %q, wanted %v %q", tc.expression,
+				de.Result, de.Rolled, tc.result, tc.rolled)
+		}
+	}
+
+	de, err := EvaluateExpression(ctx, "2x d1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(de.Dice) != 2 || de.Dice[0] == de.Dice[1] {
+		t.Errorf("got dice %v, wanted each repetition's dice", de.Dice)
+	}
+	if de.String() != "(1) = 1\n(1) = 1" {
+		t.Errorf("got %q, wanted each repetition's result", de)
+	}
+}
+
+func TestEvaluate_Grouped(t *testing.T) {
+	testCases := []struct {
+		expression string
+		result     float64
+		rolled     string
+		results    int
+	}{
+		{"{d1+5, 3d1}kh1", 6, "{(1)+5}", 0},
+		{"{d1+5, 3d1}dh1 + 1", 4, "{(1+1+1)} + 1", 0},
+		{"{2, 4d1, 3}sd", 9, "{(1+1+1+1), 3, 2}", 0},
+		{"{2, 4d1, 3}k>4", 4, "{(1+1+1+1)}", 0},
+		{"{1, 2}kl1 * 10", 10, "{1} * 10", 0},
+		{"2x {d1, 2}kh1", 4, "{{2}, {2}}", 2},
+	}
+	for _, tc := range testCases {
+		de, err := EvaluateExpression(ctx, tc.expression)
+		if err != nil {
+			t.Fatalf("error evaluating \"%s\": %s", tc.expression, err)
+		}
+		if de.Result != tc.result || de.Rolled != tc.rolled || len(de.Results) != tc.results {
+			t.Errorf("evaluated %s; got %v %q with %d results, wanted %v %q with %d", tc.expression,
+				de.Result, de.Rolled, len(de.Results), tc.result, tc.rolled, tc.results)
+		}
+	}
+
+	// the criticals of dropped items do not count
+	de, err := EvaluateExpression(ctx, "{d1, 5}kh1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if de.CritSuccess || de.CritFailure || len(de.Dice) != 1 {
+		t.Errorf("got %v with %d dice groups, wanted no criticals and the dropped die", de, len(de.Dice))
+	}
+}
+
+func TestEvaluate_Outcome(t *testing.T) {
+	testCases := []struct {
+		expression string
+		result     float64
+		outcome    *CompareOutcome
+		str        string
+	}{
+		{"d1+5 >= 6", 1, &CompareOutcome{true, 6, 0}, "(1)+5 >= 6 = 1 (pass, total 6, margin 0)"},
+		{"2d{1} > 3", 0, &CompareOutcome{false, 2, -1}, "(1+1) > 3 = 0 (fail, total 2, margin -1)"},
+		{"(3 != 4)", 1, &CompareOutcome{true, 3, -1}, "(3 != 4) = 1 (pass, total 3, margin -1)"},
+		{"3d{1} >= 2 ? 2d{1}+1 : 0", 3, &CompareOutcome{true, 3, 1}, "(1+1+1) >= 2 ? (1+1)+1 : 0 = 3 (pass, total 3, margin 1)"},
+		{"3 < 2 ? 2d{1} : 4d{1}", 4, &CompareOutcome{false, 3, 1}, "3 < 2 ? 2d{1} : (1+1+1+1) = 4 (fail, total 3, margin 1)"},
+		{"d{16}>=15", 1, &CompareOutcome{true, 16, 1}, "(16)>=15 = 1 (pass, total 16, margin 1)"},
+		{"d{15}>15", 1, &CompareOutcome{true, 15, 0}, "(15)>15 = 1 (pass, total 15, margin 0)"},
+		{"d{15}<15", 1, &CompareOutcome{true, 15, 0}, "(15)<15 = 1 (pass, total 15, margin 0)"},
+		{"2d{15}>15", 2, nil, "(15✓, 15✓) = 2"},
+		{"1d{4}=5", 0, &CompareOutcome{false, 4, -1}, "(4)=5 = 0 (fail, total 4, margin -1)"},
+		{"3d{1}>=1", 3, nil, "(1✓, 1✓, 1✓) = 3"},
+		{"(1 < 2) + (2 < 3)", 2, nil, "(1 < 2) + (2 < 3) = 2"},
+		{"1 ? 5 : 6", 5, nil, "1 ? 5 : 6 = 5"},
+	}
+	for _, tc := range testCases {
+		de, err := EvaluateExpression(ctx, tc.expression)
+		if err != nil {
+			t.Fatalf("error evaluating \"%s\": %s", tc.expression, err)
+		}
+		if de.Result != tc.result {
+			t.Errorf("evaluated %s; got result %v, wanted %v", tc.expression, de.Result, tc.result)
+		}
+		if (de.Outcome == nil) != (tc.outcome == nil) || (de.Outcome != nil && *de.Outcome != *tc.outcome) {
+			t.Errorf("evaluated %s; got outcome %v, wanted %v", tc.expression, de.Outcome, tc.outcome)
+		}
+		if de.String() != tc.str {
+			t.Errorf("evaluated %s; got %q, wanted %q", tc.expression, de.String(), tc.str)
+		}
+	}
+
+	// a comparison directly following a single die is not a success pool
+	de, err := EvaluateExpression(ctx, "d20>=15")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := de.Outcome; o == nil || o.Pass != (o.Total >= 15) || o.Margin != o.Total-15 || o.Pass != (de.Result == 1) {
+		t.Errorf("got %v, wanted the outcome of comparing d20 to 15", de)
+	}
+
+	de, err = EvaluateExpression(ctx, "2x 3 >= 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if de.Outcome != nil || len(de.Results) != 2 || de.Results[1].Outcome == nil || !de.Results[1].Outcome.Pass {
+		t.Errorf("got %v, wanted each repetition's outcome", de)
+	}
+}
+
+func TestEvaluate_Labels(t *testing.T) {
+	testCases := []struct {
+		expression string
+		labels     map[string]float64
+		rolled     string
+	}{
+		{"1d{5}[slashing] + 2d{3}[fire] + 3[slashing]", map[string]float64{"slashing": 8, "fire": 6}, "(5)[slashing] + (3+3)[fire] + 3[slashing]"},
+		{"(2d{2}+1)[ fire ]*2", map[string]float64{"fire": 10}, "((2+2)+1)[ fire ]*2"},
+		{"10 - d{3}[x]", map[string]float64{"x": -3}, "10 - (3)[x]"},
+		{"2*d{3}[x]", map[string]float64{"x": 6}, "2*(3)[x]"},
+		{"-d{3}[x] + d{4}[y]*d{2}", map[string]float64{"x": -3, "y": 8}, "-(3)[x] + (4)[y]*(2)"},
+		{"(d{6}[x] + 2)/2 - (1 - d{1}[x])", map[string]float64{"x": 4}, "((6)[x] + 2)/2 - (1 - (1)[x])"},
+		{"{d{4}[a], d{9}[b]}kh1", map[string]float64{"b": 9}, "{(9)[b]}"},
+		{"1 < 2 ? 3[hit] : 4[miss]", map[string]float64{"hit": 3}, "1 < 2 ? 3[hit] : 4[miss]"},
+		{"2x d{3}[x] + 1", map[string]float64{"x": 6}, "{(3)[x] + 1, (3)[x] + 1}"},
+		{"d{3} + 1", nil, "(3) + 1"},
+	}
+	for _, tc := range testCases {
+		de, err := EvaluateExpression(ctx, tc.expression)
+		if err != nil {
+			t.Fatalf("error evaluating \"%s\": %s", tc.expression, err)
+		}
+		if !reflect.DeepEqual(de.Labels, tc.labels) {
+			t.Errorf("evaluated %s; got labels %v, wanted %v", tc.expression, de.Labels, tc.labels)
+		}
+		if de.Rolled != tc.rolled {
+			t.Errorf("evaluated %s; got rolled %q, wanted %q", tc.expression, de.Rolled, tc.rolled)
+		}
+	}
+
+	if _, err := EvaluateExpression(ctx, "10 / d{3}[x]"); !errors.Is(err, ErrLabeledDivisor) {
+		t.Errorf("got error %v, wanted %v", err, ErrLabeledDivisor)
+	}
+	for _, expression := range []string{
+		"(1d6[fire])*(2[fire])",
+		"d{3}[x]*d{2}[y]",
+		"(1d6[fire])^2",
+		"2^d{3}[x]",
+		"(3d6[x])%4",
+		"10 % d{3}[x]",
+		"max(1d6[fire],1d8[cold])",
+		"floor(d{3}[x]/2)",
+	} {
+		if _, err := EvaluateExpression(ctx, expression); !errors.Is(err, ErrLabeledOperand) {
+			t.Errorf("evaluated %s; got error %v, wanted %v", expression, err, ErrLabeledOperand)
+		}
+	}
+
+	de, err := EvaluateExpression(ctx, "d{2}[fire] + d{2}[cold]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(de)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"labels":{"cold":2,"fire":2}`; !strings.Contains(string(b), want) {
+		t.Errorf("got JSON %s, wanted it to contain %s", b, want)
+	}
+}
+
+func TestEvaluate_Seeded(t *testing.T) {
+	evaluate := func() *ExpressionResult {
+		ctx := context.WithValue(context.Background(), dice.CtxKeySource, dice.NewSeededSource(1234))
+		de, err := EvaluateExpression(ctx, "4d6kh3 + 2d20kl1 + 3dF")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return de
+	}
+	a, b := evaluate(), evaluate()
+	if a.Rolled != b.Rolled || a.Result != b.Result {
+		t.Errorf("equally seeded evaluations differ: %v, %v", a, b)
+	}
+}
+
+func TestExpressionResult_Verify(t *testing.T) {
+	fair, err := dice.GenerateFairSource("client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), dice.CtxKeySource, fair)
+	de, err := EvaluateExpression(ctx, "4d6kh3 + 2d20kl1 + 3dF + 1d4r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := de.Verify(context.Background(), fair.Reveal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 {
+		t.Errorf("verified %d dice, want 10", n)
+	}
+
+	other, err := dice.GenerateFairSource("client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := de.Verify(context.Background(), other.Reveal()); err == nil {
+		t.Error("verified dice against another source's seeds")
+	}
+}
+
+func TestVerifyResults(t *testing.T) {
+	fair, err := dice.GenerateFairSource("client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), dice.CtxKeySource, fair)
+	var results []*ExpressionResult
+	for _, expression := range []string{
+		"3x 4d6kh3", "d4!!", "d4!p", "d4r1!!", "d4r1!p", "3d4!", "d4min3", "d4!!max5r1",
+		"d{3,5,8}!!", "d6{1,0,0,2.5,0,1}r1", "5d6u", "{d20, d20}kh1", "d20 >= 10 ? 2d6 : d4",
+	} {
+		de, err := EvaluateExpression(ctx, expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, de)
+	}
+	reveal := fair.Reveal()
+	if _, err := VerifyResults(context.Background(), reveal, results...); err != nil {
+		t.Fatalf("verifying the session: %v", err)
+	}
+
+	// results must be every result rolled with the source, in order
+	if _, err := VerifyResults(context.Background(), reveal, results[1:]...); !errors.Is(err, ErrReplayMismatch) {
+		t.Errorf("results without the first: got %v, want %v", err, ErrReplayMismatch)
+	}
+	if _, err := VerifyResults(context.Background(), reveal, results[1], results[0]); !errors.Is(err, ErrReplayMismatch) {
+		t.Errorf("reordered results: got %v, want %v", err, ErrReplayMismatch)
+	}
+
+	forge := func(expression string, edit func([]*dice.Die)) error {
+		fair, err := dice.GenerateFairSource("client")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := context.WithValue(context.Background(), dice.CtxKeySource, fair)
+		de, err := EvaluateExpression(ctx, expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rolled []*dice.Die
+		for _, group := range de.Dice {
+			for _, r := range group.Group {
+				rolled = append(rolled, r.(*dice.Die))
+			}
+		}
+		edit(rolled)
+		_, err = de.Verify(context.Background(), fair.Reveal())
+		return err
+	}
+	forgeries := []struct {
+		name       string
+		expression string
+		edit       func([]*dice.Die)
+	}{
+		{"reused nonce", "2d6", func(d []*dice.Die) {
+			r := *d[0].Result
+			d[1].Result = &r
+		}},
+		{"value", "d6", func(d []*dice.Die) {
+			d[0].Result.Value = float64(int(d[0].Result.Value)%6 + 1)
+		}},
+		{"clamp", "d6min2", func(d []*dice.Die) {
+			prior := dice.PriorResult{Value: d[0].Result.Value, Kind: dice.ReplacementMin,
+				Reason: "min2", Nonce: d[0].Result.Nonce}
+			if d[0].Result.Value == 6 {
+				prior.Value = 1
+			}
+			d[0].Result.History = []dice.PriorResult{prior}
+			d[0].Result.Value = 6
+		}},
+		{"reroll kind", "d6!!", func(d []*dice.Die) {
+			d[0].Result.History = append(d[0].Result.History, dice.PriorResult{
+				Value: d[0].Result.Value, Kind: dice.ReplacementReroll, Nonce: d[0].Result.Nonce})
+		}},
+		{"dropped", "2d20kh1", func(d []*dice.Die) {
+			d[0].Result.Dropped, d[1].Result.Dropped = d[1].Result.Dropped, d[0].Result.Dropped
+			if d[0].Result.Value == d[1].Result.Value {
+				d[0].Result.Value = 21
+			}
+		}},
+		{"unfair die", "3d6", func(d []*dice.Die) {
+			d[2].Result.Nonce = nil
+		}},
+	}
+	for _, tc := range forgeries {
+		if err := forge(tc.expression, tc.edit); err == nil {
+			t.Errorf("%s: forged %s was verified", tc.name, tc.expression)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package math
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/travis-g/dice"
+)
+
+// ErrReplayMismatch is returned when a verified result differs from the
+// result of replaying its expression with the revealed seeds.
+var ErrReplayMismatch = errors.New("result differs from its replay")
+
+// Verify checks an ExpressionResult rolled with a dice.FairSource against the
+// source's revealed seeds, as the only result rolled with the source. See
+// VerifyResults.
+func (de *ExpressionResult) Verify(ctx context.Context, reveal *dice.FairReveal) (int, error) {
+	return VerifyResults(ctx, reveal, de)
+}
+
+// VerifyResults checks ExpressionResults rolled in order with the same
+// dice.FairSource against the source's revealed seeds.
+//
+// The dice recorded within the results are not trusted. Instead, the server
+// seed is checked against its commitment, and each result's Original
+// expression is evaluated again with a source rebuilt from the seeds, which
+// uses nonces in order from 0. Every die of each replay must match the
+// recorded die: its type and size, value, nonce, and the history of rerolls,
+// explosions, and clamps applied by the expression's modifiers. Results whose
+// nonces were skipped, reused, or reordered therefore fail to verify, as do
+// results with forged values. Variables within the expressions are bound by
+// the context's parameters.
+//
+// VerifyResults returns the number of dice verified, and an error describing
+// the first die that could not be verified, if any.
+func VerifyResults(ctx context.Context, reveal *dice.FairReveal, results ...*ExpressionResult) (int, error) {
+	source, err := reveal.Source()
+	if err != nil {
+		return 0, err
+	}
+	ctx = context.WithValue(ctx, dice.CtxKeySource, source)
+	var verified int
+	for i, de := range results {
+		if de == nil {
+			return verified, ErrNilResult
+		}
+		n, err := verifyResult(ctx, de)
+		verified += n
+		if err != nil {
+			if len(results) > 1 {
+				err = fmt.Errorf("result %d: %w", i, err)
+			}
+			return verified, err
+		}
+	}
+	return verified, nil
+}
+
+// verifyResult replays a result's expression and checks the result against
+// the replay. It returns the number of dice that matched.
+func verifyResult(ctx context.Context, de *ExpressionResult) (int, error) {
+	// each expression is rolled with its own roll counter
+	ctx = context.WithValue(ctx, dice.CtxKeyTotalRolls, new(uint64))
+	replay, err := EvaluateExpression(ctx, de.Original)
+	if err != nil {
+		return 0, fmt.Errorf("replaying %q: %w", de.Original, err)
+	}
+	if len(de.Dice) != len(replay.Dice) {
+		return 0, fmt.Errorf("%d dice groups, but replaying %q rolls %d: %w",
+			len(de.Dice), de.Original, len(replay.Dice), ErrReplayMismatch)
+	}
+	var verified int
+	for i, group := range de.Dice {
+		want := replay.Dice[i]
+		if group == nil || len(group.Group) != len(want.Group) {
+			return verified, fmt.Errorf("dice group %d: %d dice, but the replay rolls %d: %w",
+				i, groupLen(group), len(want.Group), ErrReplayMismatch)
+		}
+		for j, roller := range group.Group {
+			die, ok := roller.(*dice.Die)
+			if !ok {
+				return verified, fmt.Errorf("dice group %d: roller %d: %T is not a die", i, j, roller)
+			}
+			replayed, ok := want.Group[j].(*dice.Die)
+			if !ok {
+				return verified, fmt.Errorf("dice group %d: replayed roller %d: %T is not a die", i, j, want.Group[j])
+			}
+			if err := matchDie(die, replayed); err != nil {
+				return verified, fmt.Errorf("dice group %d: die %d: %w", i, j, err)
+			}
+			verified++
+		}
+	}
+	if de.Result != replay.Result {
+		return verified, fmt.Errorf("result %v, but the replay totals %v: %w",
+			de.Result, replay.Result, ErrReplayMismatch)
+	}
+	return verified, nil
+}
+
+// groupLen returns the number of dice in a possibly nil group.
+func groupLen(group *dice.RollerGroup) int {
+	if group == nil {
+		return 0
+	}
+	return len(group.Group)
+}
+
+// matchDie checks a recorded die against the same die of a replay.
+func matchDie(die, want *dice.Die) error {
+	if die.Result == nil {
+		return dice.ErrUnrolled
+	}
+	got, exp := die.Result, want.Result
+	if got.Nonce == nil && exp.Nonce != nil {
+		return dice.ErrUnverifiable
+	}
+	if die.Type != want.Type || die.Size != want.Size {
+		return fmt.Errorf("die %s%d, but the replay rolls a %s%d: %w",
+			die.Type, die.Size, want.Type, want.Size, ErrReplayMismatch)
+	}
+	if got.Value != exp.Value || !sameNonce(got.Nonce, exp.Nonce) || got.Dropped != exp.Dropped {
+		return fmt.Errorf("rolled %v with nonce %s, but the replay rolls %v with nonce %s: %w",
+			got.Value, nonceString(got.Nonce), exp.Value, nonceString(exp.Nonce), ErrReplayMismatch)
+	}
+	if len(got.History) != len(exp.History) {
+		return fmt.Errorf("%d prior values, but the replay has %d: %w",
+			len(got.History), len(exp.History), ErrReplayMismatch)
+	}
+	for k, prior := range got.History {
+		p := exp.History[k]
+		if prior.Value != p.Value || prior.Kind != p.Kind || !sameNonce(prior.Nonce, p.Nonce) {
+			return fmt.Errorf("prior value %d is %v by %q, but the replay has %v by %q: %w",
+				k, prior.Value, prior.Kind, p.Value, p.Kind, ErrReplayMismatch)
+		}
+	}
+	return nil
+}
+
+// sameNonce returns whether two optional nonces are equal.
+func sameNonce(a, b *uint64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// nonceString formats an optional nonce.
+func nonceString(nonce *uint64) string {
+	if nonce == nil {
+		return "none"
+	}
+	return strconv.FormatUint(*nonce, 10)
+}
@@ -29,6 +29,46 @@ func (m ModifierList) String() string {
 	return b.String()
 }
 
+// Copy returns a deep copy of the list, so that modifiers that may be altered
+// when applied are not shared between dice. Modifiers of unknown types are not
+// copied.
+func (m ModifierList) Copy() ModifierList {
+	if m == nil {
+		return nil
+	}
+	list := make(ModifierList, len(m))
+	for i, mod := range m {
+		switch mod := mod.(type) {
+		case *RerollModifier:
+			c := *mod
+			c.CompareTarget = mod.CompareTarget.copy()
+			list[i] = &c
+		case *ExplodeModifier:
+			c := *mod
+			c.CompareTarget = mod.CompareTarget.copy()
+			list[i] = &c
+		case *CriticalSuccessModifier:
+			list[i] = &CriticalSuccessModifier{mod.CompareTarget.copy()}
+		case *CriticalFailureModifier:
+			list[i] = &CriticalFailureModifier{mod.CompareTarget.copy()}
+		case *SuccessModifier:
+			list[i] = &SuccessModifier{mod.CompareTarget.copy()}
+		case *FailureModifier:
+			list[i] = &FailureModifier{mod.CompareTarget.copy()}
+		case *DropKeepModifier:
+			c := *mod
+			c.CompareTarget = mod.CompareTarget.copy()
+			list[i] = &c
+		case *SortModifier:
+			c := *mod
+			list[i] = &c
+		default:
+			list[i] = mod
+		}
+	}
+	return list
+}
+
 // CompareOp is an comparison operator usable in modifiers.
 type CompareOp int
 
@@ -99,6 +139,31 @@ type CompareTarget struct {
 	Target  int       `json:"target"`
 }
 
+// copy returns a copy of the compare target.
+func (c *CompareTarget) copy() *CompareTarget {
+	if c == nil {
+		return nil
+	}
+	ct := *c
+	return &ct
+}
+
+// match returns whether a value matches the compare target. As with Roll20, <
+// and > are inclusive of the target; an empty comparison is treated as an
+// equality. The second return value is false if the comparison is unknown.
+func (c *CompareTarget) match(v float64) (bool, bool) {
+	target := float64(c.Target)
+	switch c.Compare {
+	case EMPTY, EQL:
+		return v == target, true
+	case LSS, LEQ:
+		return v <= target, true
+	case GTR, GEQ:
+		return v >= target, true
+	}
+	return false, false
+}
+
 // RerollModifier is a modifier that rerolls a Die if a comparison against the
 // compare target is true.
 type RerollModifier struct {
@@ -123,6 +188,9 @@ func (m *RerollModifier) String() string {
 	var b strings.Builder
 	write := b.WriteString
 	write("r")
+	if m.Once {
+		write("o")
+	}
 	// inferred equals if not specified
 	if m.Compare != EQL {
 		write(m.Compare.String())
@@ -131,22 +199,22 @@ func (m *RerollModifier) String() string {
 	return b.String()
 }
 
-// Apply executes a RerollModifier against a Roller. The modifier may be
-// slightly modified the first time it is applied to ensure property
-// consistency.
+// Apply executes a RerollModifier against a Roller. An empty comparison is
+// treated as an equality.
 //
 // The full roll needs to be recalculated in the event that one result may be
 // acceptable for one reroll criteria, but not for one that was already
 // evaluated. An ErrRerolled error will be returned if the die was rerolled in
-// case other modifiers on the die need to be reapplied. Impossible rerolls and
+// case other modifiers on the die need to be reapplied. A modifier that rerolls
+// once does nothing if it has already rerolled the die. Impossible rerolls and
 // impossible combinations of rerolls may cause a stack overflow from recursion
 // without a safeguard like MaxRerolls.
 func (m *RerollModifier) Apply(ctx context.Context, r Roller) error {
 	if m == nil {
 		return errors.New("nil modifier")
 	}
-	if m.Compare == EMPTY {
-		m.Compare = EQL
+	if die, ok := r.(*Die); ok && m.Once && die.rerolledBy(m) {
+		return nil
 	}
 	ok, err := m.Valid(ctx, r)
 	if err != nil {
@@ -155,9 +223,13 @@ func (m *RerollModifier) Apply(ctx context.Context, r Roller) error {
 	if ok {
 		return nil
 	}
+	ctx = withModifier(ctx, m)
 	// if once, do only once
 	if m.Once {
-		return r.Reroll(ctx)
+		if err := r.Reroll(ctx); err != nil {
+			return err
+		}
+		return ErrRerolled
 	}
 	// reroll until valid
 	return rerollApplyTail(ctx, m, r)
@@ -198,9 +270,6 @@ func (m *RerollModifier) Valid(ctx context.Context, r Roller) (bool, error) {
 		result float64
 		err    error
 	)
-	if m.Compare == EMPTY {
-		m.Compare = EQL
-	}
 	if result, err = r.Total(ctx); err != nil {
 		// return invalid if error
 		return false, err
@@ -208,7 +277,7 @@ func (m *RerollModifier) Valid(ctx context.Context, r Roller) (bool, error) {
 	switch m.Compare {
 	// until the comparison operation succeeds and the reroll passes, keep
 	// rerolling.
-	case EQL:
+	case EMPTY, EQL:
 		return result != float64(m.Target), nil
 	case LSS, LEQ:
 		return !(result <= float64(m.Target)), nil
@@ -222,6 +291,81 @@ func (m *RerollModifier) Valid(ctx context.Context, r Roller) (bool, error) {
 	}
 }
 
+// A ClampMethod is the bound a ClampModifier applies to a die's value.
+type ClampMethod string
+
+// Clamp methods.
+const (
+	ClampMethodMin ClampMethod = "min"
+	ClampMethodMax ClampMethod = "max"
+)
+
+// A ClampModifier is a die-level modifier that raises a die's value to a
+// minimum or lowers it to a maximum, like 4d6min2 or 2d20max15. Unlike
+// dropping, the clamped die still counts towards its group's total. Clamps are
+// applied after a die's other modifiers, so a clamped value is never rerolled
+// or exploded, and the die's critical flags reflect its natural roll.
+type ClampModifier struct {
+	Method ClampMethod `json:"op"`
+	Target int         `json:"target"`
+}
+
+// MarshalJSON marshals the modifier into JSON and includes an internal type
+// property.
+func (m *ClampModifier) MarshalJSON() ([]byte, error) {
+	type Faux ClampModifier
+	return json.Marshal(&struct {
+		Type string `json:"type"`
+		*Faux
+	}{
+		Type: "clamp",
+		Faux: (*Faux)(m),
+	})
+}
+
+func (m *ClampModifier) String() string {
+	return string(m.Method) + strconv.Itoa(m.Target)
+}
+
+// Apply clamps the value of a rolled Die. The replaced value is kept in the
+// Result's History.
+func (m *ClampModifier) Apply(ctx context.Context, r Roller) error {
+	die, ok := r.(*Die)
+	if !ok {
+		return errors.New("target for modifier not a die")
+	}
+	if die.Result == nil {
+		return ErrUnrolled
+	}
+	value := m.clamp(die.Result.Value)
+	if value == die.Result.Value {
+		return nil
+	}
+	die.Result.History = append(die.Result.History, PriorResult{
+		Value:  die.Result.Value,
+		Kind:   Replacement(m.Method),
+		Reason: m.String(),
+		Nonce:  die.Result.Nonce,
+	})
+	die.Result.Value = value
+	return nil
+}
+
+func (m *ClampModifier) clamp(v float64) float64 {
+	target := float64(m.Target)
+	switch m.Method {
+	case ClampMethodMin:
+		if v < target {
+			return target
+		}
+	case ClampMethodMax:
+		if v > target {
+			return target
+		}
+	}
+	return v
+}
+
 // A DropKeepMethod is a method to use when evaluating a drop/keep modifier
 // against a dice group.
 type DropKeepMethod string
@@ -235,71 +379,332 @@ const (
 	DropKeepMethodKeep        DropKeepMethod = "k"
 	DropKeepMethodKeepLowest  DropKeepMethod = "kl"
 	DropKeepMethodKeepHighest DropKeepMethod = "kh"
+	DropKeepMethodKeepMiddle  DropKeepMethod = "km"
 )
 
 // A DropKeepModifier is a modifier to drop the highest or lowest Num dice
 // within a group by marking them as Dropped. The Method used to apply the
 // modifier defines if the dice are dropped or kept (meaning the Num highest
-// dice are not dropped).
+// dice are not dropped). Keeping the middle Num dice drops the remaining dice
+// evenly from either end, dropping the extra die from the highest end if the
+// remainder is odd.
+//
+// If the modifier has a CompareTarget, Num is unused and the drop (d) or keep
+// (k) Method instead drops or keeps every die that matches the comparison.
 type DropKeepModifier struct {
 	Method DropKeepMethod `json:"op,omitempty"`
 	Num    int            `json:"num"`
+	*CompareTarget
+}
+
+// MarshalJSON marshals the modifier into JSON and includes an internal type
+// property.
+func (d *DropKeepModifier) MarshalJSON() ([]byte, error) {
+	type Faux DropKeepModifier
+	return json.Marshal(&struct {
+		Type string `json:"type"`
+		*Faux
+	}{
+		Type: "drop_keep",
+		Faux: (*Faux)(d),
+	})
 }
 
 func (d *DropKeepModifier) String() string {
-	return string(d.Method)
+	if d.CompareTarget != nil {
+		// the comparison must always be written to distinguish it from Num
+		compare := d.Compare
+		if compare == EMPTY {
+			compare = EQL
+		}
+		return string(d.Method) + compare.String() + strconv.Itoa(d.Target)
+	}
+	return string(d.Method) + strconv.Itoa(d.Num)
 }
 
 // Apply executes a DropKeepModifier against a Roller. If the Roller is not a
-// Group an error is returned.
+// Group an error is returned. Dice are ranked by their values, regardless of
+// whether an earlier modifier dropped them.
 func (d *DropKeepModifier) Apply(ctx context.Context, r Roller) error {
 	group, ok := r.(*RollerGroup)
 	if !ok {
 		return errors.New("target for modifier not a dice group")
 	}
 
+	if d.CompareTarget != nil {
+		return d.applyCompare(ctx, group)
+	}
+
 	// create a duplicate of the slice to sort
 	dice := group.Copy()
-
-	// TODO: do these dice need to be sorted by their result value/should
-	// already-dropped dice be filtered and excluded?
-	sort.Slice(dice, func(i, j int) bool {
-		ti, _ := (dice[i]).Total(ctx)
-		tj, _ := (dice[j]).Total(ctx)
-		return ti < tj
+	sort.SliceStable(dice, func(i, j int) bool {
+		vi, _ := (dice[i]).Value(ctx)
+		vj, _ := (dice[j]).Value(ctx)
+		return vi < vj
 	})
 
+	// drop dice in sorted positions [from, to)
+	drop := func(from, to int) {
+		for i := from; i < to && i < len(dice); i++ {
+			if i >= 0 {
+				dice[i].Drop(ctx, true)
+			}
+		}
+	}
 	switch d.Method {
 	case DropKeepMethodDrop, DropKeepMethodDropLowest:
 		// drop lowest Num
-		for i := 0; i < d.Num && i < len(dice); i++ {
-			dice[i].Drop(ctx, true)
-		}
+		drop(0, d.Num)
 	case DropKeepMethodKeep, DropKeepMethodKeepHighest:
 		// drop all but highest Num
-		for i := 0; i < len(dice)-d.Num && i < len(dice); i++ {
-			dice[i].Drop(ctx, true)
-		}
+		drop(0, len(dice)-d.Num)
 	case DropKeepMethodDropHighest:
-		for i := len(dice) - d.Num; i < len(dice); i++ {
-			dice[i].Drop(ctx, true)
-		}
+		drop(len(dice)-d.Num, len(dice))
 	case DropKeepMethodKeepLowest:
-		for i := d.Num; i < len(dice); i++ {
-			dice[i].Drop(ctx, true)
-		}
+		drop(d.Num, len(dice))
+	case DropKeepMethodKeepMiddle:
+		low := (len(dice) - d.Num) / 2
+		drop(0, low)
+		drop(low+d.Num, len(dice))
 	default:
 		return &ErrNotImplemented{"unknown drop/keep method"}
 	}
 	return nil
 }
 
+// applyCompare drops the dice of a group that match (or, if keeping, that do
+// not match) the modifier's comparison.
+func (d *DropKeepModifier) applyCompare(ctx context.Context, group *RollerGroup) error {
+	var keep bool
+	switch d.Method {
+	case DropKeepMethodDrop:
+	case DropKeepMethodKeep:
+		keep = true
+	default:
+		return &ErrNotImplemented{
+			fmt.Sprintf("drop/keep method %q with a comparison", d.Method),
+		}
+	}
+	for _, r := range group.Group {
+		v, err := r.Value(ctx)
+		if err != nil {
+			return err
+		}
+		match, ok := d.match(v)
+		if !ok {
+			return &ErrNotImplemented{
+				fmt.Sprintf("uncaught case for drop/keep compare: %s", d.Compare),
+			}
+		}
+		if match != keep {
+			r.Drop(ctx, true)
+		}
+	}
+	return nil
+}
+
+// A UniqueModifier is a group-level modifier that rerolls any die that rolled
+// the same value as an earlier die in the group until every die in the group is
+// unique. Rerolled dice have their own modifiers applied again, as when they
+// were first rolled. A group with more dice than the faces its dice can settle
+// on returns ErrImpossibleRoll.
+type UniqueModifier struct{}
+
+// MarshalJSON marshals the modifier into JSON and includes an internal type
+// property.
+func (m *UniqueModifier) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Type string `json:"type"`
+	}{
+		Type: "unique",
+	})
+}
+
+func (m *UniqueModifier) String() string {
+	return "u"
+}
+
+// Apply rerolls the duplicate dice of a RollerGroup.
+func (m *UniqueModifier) Apply(ctx context.Context, r Roller) error {
+	group, ok := r.(*RollerGroup)
+	if !ok {
+		return errors.New("target for modifier not a dice group")
+	}
+	ctx = withModifier(ctx, m)
+	seen := make(map[Face]bool, len(group.Group))
+	for _, roller := range group.Group {
+		die, ok := roller.(*Die)
+		if !ok {
+			return errors.New("roller not a die")
+		}
+		if die.Result == nil {
+			return ErrUnrolled
+		}
+		if len(group.Group) > die.distinctFaces() {
+			return ErrImpossibleRoll
+		}
+		for seen[Face{die.Result.Value, die.Result.Symbol}] {
+			if err := m.reroll(ctx, die); err != nil {
+				return err
+			}
+		}
+		seen[Face{die.Result.Value, die.Result.Symbol}] = true
+	}
+	return nil
+}
+
+// reroll fully rolls a duplicate die again, applying its modifiers, and keeps
+// the replaced value in the new Result's History.
+func (m *UniqueModifier) reroll(ctx context.Context, die *Die) error {
+	prior := die.Result
+	if err := die.FullRoll(ctx); err != nil {
+		return err
+	}
+	die.Rerolls++
+	history := make([]PriorResult, 0, len(prior.History)+1+len(die.Result.History))
+	history = append(history, prior.History...)
+	history = append(history, PriorResult{
+		Value:  prior.Value,
+		Kind:   ReplacementReroll,
+		Reason: m.String(),
+		Nonce:  prior.Nonce,
+	})
+	die.Result.History = append(history, die.Result.History...)
+	return nil
+}
+
+// A SuccessModifier is a group-level modifier that makes a group a
+// success-counting pool: each die in the group whose value matches the compare
+// target is marked as a success, and the group's total becomes the number of
+// successes less the number of failures.
+type SuccessModifier struct {
+	*CompareTarget
+}
+
+// MarshalJSON marshals the modifier into JSON and includes an internal type
+// property.
+func (m *SuccessModifier) MarshalJSON() ([]byte, error) {
+	type Faux SuccessModifier
+	return json.Marshal(&struct {
+		Type string `json:"type"`
+		*Faux
+	}{
+		Type: "success",
+		Faux: (*Faux)(m),
+	})
+}
+
+func (m *SuccessModifier) String() string {
+	// the comparison must always be written, as there is no prefix
+	compare := m.Compare
+	if compare == EMPTY {
+		compare = EQL
+	}
+	return compare.String() + strconv.Itoa(m.Target)
+}
+
+// Apply marks each die within a RollerGroup as a success if it matches the
+// modifier, or as neutral if it has not already been marked.
+func (m *SuccessModifier) Apply(ctx context.Context, r Roller) error {
+	return markOutcomes(r, m.CompareTarget, PoolOutcomeSuccess)
+}
+
+// A FailureModifier is a group-level modifier that marks each die in a
+// success-counting pool that matches the compare target as a failure, which
+// subtracts from the pool's successes. A failure takes precedence over a
+// success.
+type FailureModifier struct {
+	*CompareTarget
+}
+
+// MarshalJSON marshals the modifier into JSON and includes an internal type
+// property.
+func (m *FailureModifier) MarshalJSON() ([]byte, error) {
+	type Faux FailureModifier
+	return json.Marshal(&struct {
+		Type string `json:"type"`
+		*Faux
+	}{
+		Type: "failure",
+		Faux: (*Faux)(m),
+	})
+}
+
+func (m *FailureModifier) String() string {
+	var b strings.Builder
+	write := b.WriteString
+	write("f")
+	// inferred equals if not specified
+	if m.Compare != EQL {
+		write(m.Compare.String())
+	}
+	write(strconv.Itoa(m.Target))
+	return b.String()
+}
+
+// Apply marks each die within a RollerGroup as a failure if it matches the
+// modifier, or as neutral if it has not already been marked.
+func (m *FailureModifier) Apply(ctx context.Context, r Roller) error {
+	return markOutcomes(r, m.CompareTarget, PoolOutcomeFailure)
+}
+
+// markOutcomes marks the dice of a group that match a compare target with an
+// outcome. Unmarked dice that do not match are marked neutral, and failures
+// are never overridden.
+func markOutcomes(r Roller, ct *CompareTarget, outcome PoolOutcome) error {
+	group, ok := r.(*RollerGroup)
+	if !ok {
+		return errors.New("target for modifier not a dice group")
+	}
+	if ct == nil {
+		return errors.New("nil modifier")
+	}
+	for _, roller := range group.Group {
+		die, ok := roller.(*Die)
+		if !ok {
+			return errors.New("roller not a die")
+		}
+		if die.Result == nil {
+			return ErrUnrolled
+		}
+		match, ok := ct.match(die.Result.Value)
+		if !ok {
+			return &ErrNotImplemented{
+				fmt.Sprintf("uncaught case for %s compare: %s", outcome, ct.Compare),
+			}
+		}
+		switch {
+		case die.Outcome == PoolOutcomeFailure:
+		case match:
+			die.Outcome = outcome
+		case die.Outcome == PoolOutcomeNone:
+			die.Outcome = PoolOutcomeNeutral
+		}
+	}
+	return nil
+}
+
 // A CriticalSuccessModifier shifts or sets the compare point/range used to
-// classify a die's result as a critical success.
+// classify a die's result as a critical success. If a die has any critical
+// success modifiers, its default critical success on a maximum roll is replaced
+// by whether any of the modifiers match its result.
 type CriticalSuccessModifier struct {
 	*CompareTarget
 }
 
+// MarshalJSON marshals the modifier into JSON and includes an internal type
+// property.
+func (m *CriticalSuccessModifier) MarshalJSON() ([]byte, error) {
+	type Faux CriticalSuccessModifier
+	return json.Marshal(&struct {
+		Type string `json:"type"`
+		*Faux
+	}{
+		Type: "critical_success",
+		Faux: (*Faux)(m),
+	})
+}
+
 func (m *CriticalSuccessModifier) String() string {
 	var b strings.Builder
 	write := b.WriteString
@@ -312,12 +717,54 @@ func (m *CriticalSuccessModifier) String() string {
 	return b.String()
 }
 
+// Apply sets the critical success flag of a rolled Die based on all of the
+// die's critical success modifiers.
+func (m *CriticalSuccessModifier) Apply(ctx context.Context, r Roller) error {
+	if m == nil {
+		return errors.New("nil modifier")
+	}
+	die, ok := r.(*Die)
+	if !ok {
+		return errors.New("roller not a die")
+	}
+	if die.Result == nil {
+		return ErrUnrolled
+	}
+	crit := false
+	for _, mod := range die.Modifiers {
+		if cs, ok := mod.(*CriticalSuccessModifier); ok && !crit {
+			if crit, ok = cs.match(die.Result.Value); !ok {
+				return &ErrNotImplemented{
+					fmt.Sprintf("uncaught case for critical success compare: %s", cs.Compare),
+				}
+			}
+		}
+	}
+	die.CritSuccess = crit
+	return nil
+}
+
 // A CriticalFailureModifier shifts or sets the compare point/range used to
-// classify a die's result as a critical failure.
+// classify a die's result as a critical failure. If a die has any critical
+// failure modifiers, its default critical failure on a minimum roll is replaced
+// by whether any of the modifiers match its result.
 type CriticalFailureModifier struct {
 	*CompareTarget
 }
 
+// MarshalJSON marshals the modifier into JSON and includes an internal type
+// property.
+func (m *CriticalFailureModifier) MarshalJSON() ([]byte, error) {
+	type Faux CriticalFailureModifier
+	return json.Marshal(&struct {
+		Type string `json:"type"`
+		*Faux
+	}{
+		Type: "critical_failure",
+		Faux: (*Faux)(m),
+	})
+}
+
 func (m *CriticalFailureModifier) String() string {
 	var b strings.Builder
 	write := b.WriteString
@@ -330,6 +777,33 @@ func (m *CriticalFailureModifier) String() string {
 	return b.String()
 }
 
+// Apply sets the critical failure flag of a rolled Die based on all of the
+// die's critical failure modifiers.
+func (m *CriticalFailureModifier) Apply(ctx context.Context, r Roller) error {
+	if m == nil {
+		return errors.New("nil modifier")
+	}
+	die, ok := r.(*Die)
+	if !ok {
+		return errors.New("roller not a die")
+	}
+	if die.Result == nil {
+		return ErrUnrolled
+	}
+	fumble := false
+	for _, mod := range die.Modifiers {
+		if cf, ok := mod.(*CriticalFailureModifier); ok && !fumble {
+			if fumble, ok = cf.match(die.Result.Value); !ok {
+				return &ErrNotImplemented{
+					fmt.Sprintf("uncaught case for critical failure compare: %s", cf.Compare),
+				}
+			}
+		}
+	}
+	die.CritFailure = fumble
+	return nil
+}
+
 // SortDirection is a possible direction for sorting dice.
 type SortDirection uint8
 
@@ -367,15 +841,121 @@ func (s *SortModifier) Apply(ctx context.Context, r Roller) error {
 	return nil
 }
 
+// A MatchModifier is a group-level modifier that finds the sets of dice in a
+// group that rolled the same face, like in the One Roll Engine. Only sets of at
+// least Min dice are recorded in the group's Sets, widest first; Min defaults to
+// 2. The number of pairs in the group is recorded regardless of Min, counting a
+// set of four as two pairs. Dropped dice are not matched.
+type MatchModifier struct {
+	Min int `json:"min,omitempty"`
+}
+
+// MarshalJSON marshals the modifier into JSON and includes an internal type
+// property.
+func (m *MatchModifier) MarshalJSON() ([]byte, error) {
+	type Faux MatchModifier
+	return json.Marshal(&struct {
+		Type string `json:"type"`
+		*Faux
+	}{
+		Type: "match",
+		Faux: (*Faux)(m),
+	})
+}
+
+func (m *MatchModifier) String() string {
+	if m.Min == 0 {
+		return "m"
+	}
+	return "m" + strconv.Itoa(m.Min)
+}
+
+// Apply records the sets and pairs of a RollerGroup.
+func (m *MatchModifier) Apply(ctx context.Context, r Roller) error {
+	group, ok := r.(*RollerGroup)
+	if !ok {
+		return errors.New("target for modifier not a dice group")
+	}
+	least := m.Min
+	if least == 0 {
+		least = 2
+	}
+
+	widths := make(map[Face]int)
+	for _, roller := range group.Group {
+		if roller.IsDropped(ctx) {
+			continue
+		}
+		v, err := roller.Value(ctx)
+		if err != nil {
+			return err
+		}
+		face := Face{Value: v}
+		if die, ok := roller.(*Die); ok {
+			face.Symbol = die.Result.Symbol
+		}
+		widths[face]++
+	}
+
+	group.Sets = nil
+	group.Pairs = 0
+	for face, width := range widths {
+		group.Pairs += width / 2
+		if width >= least {
+			group.Sets = append(group.Sets, Set{Width: width, Height: face.Value, Symbol: face.Symbol})
+		}
+	}
+	sort.Slice(group.Sets, func(i, j int) bool {
+		a, b := group.Sets[i], group.Sets[j]
+		switch {
+		case a.Width != b.Width:
+			return a.Width > b.Width
+		case a.Height != b.Height:
+			return a.Height > b.Height
+		}
+		return a.Symbol < b.Symbol
+	})
+	return nil
+}
+
+// An ExplodeModifier is a modifier that rolls additional dice whenever a die's
+// roll matches the compare target, or its maximum value if the target is 0.
+//
+// A compounding explosion (!!) adds each additional roll to the same die's
+// value rather than rolling a new die. A penetrating explosion (!p) also adds
+// each additional roll to the die, but with one subtracted from each, as in
+// Hackmaster. If Once is set (!o) the die explodes at most once. Additional
+// rolls count towards the context's maximum rolls, which caps explosions.
 type ExplodeModifier struct {
 	*CompareTarget
-	Once bool `json:"once,omitempty"`
+	Once      bool `json:"once,omitempty"`
+	Compound  bool `json:"compound,omitempty"`
+	Penetrate bool `json:"penetrate,omitempty"`
+}
+
+// MarshalJSON marshals the modifier into JSON and includes an internal type
+// property.
+func (m *ExplodeModifier) MarshalJSON() ([]byte, error) {
+	type Faux ExplodeModifier
+	return json.Marshal(&struct {
+		Type string `json:"type"`
+		*Faux
+	}{
+		Type: "explode",
+		Faux: (*Faux)(m),
+	})
 }
 
 func (m *ExplodeModifier) String() string {
 	var b strings.Builder
 	write := b.WriteString
 	write("!")
+	if m.Compound {
+		write("!")
+	}
+	if m.Penetrate {
+		write("p")
+	}
 	if m.Once {
 		write("o")
 	}
@@ -389,61 +969,180 @@ func (m *ExplodeModifier) String() string {
 	return b.String()
 }
 
+// Apply executes an ExplodeModifier against a Die. A plain explosion adds a new
+// die with a copy of the die's modifiers to the die's parent RollerGroup, which
+// will be rolled as the group continues to be rolled, and may itself explode.
+// A die that would explode on every face returns ErrImpossibleRoll.
 func (m *ExplodeModifier) Apply(ctx context.Context, r Roller) error {
+	if m == nil {
+		return errors.New("nil modifier")
+	}
 	die, ok := r.(*Die)
 	if !ok {
 		return errors.New("roller not a die")
 	}
-
-	group, ok := die.Parent().(*Group)
+	if die.Result == nil {
+		return ErrUnrolled
+	}
+	lo, hi := die.faceRange()
+	matchMin, ok := m.match(die, lo)
 	if !ok {
-		return errors.New("parent is not a Group")
+		return &ErrNotImplemented{
+			fmt.Sprintf("uncaught case for exploding compare: %s", m.Compare),
+		}
+	}
+	if matchMax, _ := m.match(die, hi); matchMin && matchMax {
+		// every face would explode, so the die would never stop rolling
+		return ErrImpossibleRoll
+	}
+	if m.Compound || m.Penetrate {
+		return m.compound(ctx, die)
 	}
-	if group == nil {
-		return errors.New("parent is nil")
+
+	explode, err := m.Valid(ctx, die)
+	if err != nil || !explode {
+		return err
+	}
+	parent := die.Parent()
+	if parent == nil {
+		return errors.New("exploding die has no group")
+	}
+	modifiers := die.Modifiers.Copy()
+	if m.Once {
+		// the new die must not explode again
+		for i, mod := range die.Modifiers {
+			if mod == m {
+				modifiers = append(modifiers[:i], modifiers[i+1:]...)
+				break
+			}
+		}
+	}
+	parent.Add(&Die{
+		Type:      die.Type,
+		Size:      die.Size,
+		Faces:     die.Faces,
+		Weights:   die.Weights,
+		Modifiers: modifiers,
+		cause:     m,
+	})
+	return nil
+}
+
+// compound rolls the die again and adds the roll to the die's value for as long
+// as each new roll matches the modifier. Each roll of the chain is rerolled by
+// the die's reroll modifiers, as its first roll is. Each intermediate value is
+// kept in the die's history.
+func (m *ExplodeModifier) compound(ctx context.Context, die *Die) error {
+	ctx = withModifier(ctx, m)
+	kind := ReplacementCompound
+	if m.Penetrate {
+		kind = ReplacementPenetrate
 	}
-	if ok, err := m.Valid(ctx, r); err == nil && ok {
-		group.Add(&Die{
-			Type:      die.Type,
-			Size:      die.Size,
-			Modifiers: die.Modifiers, // FIXME: these need to be a copy
-			parent:    group,
+	roll := die.Result.Value
+	for explode, _ := m.match(die, roll); explode; explode, _ = m.match(die, roll) {
+		prior := die.Result
+		err := die.Roll(ctx)
+		if err == nil {
+			err = die.applyRerolls(ctx)
+		}
+		if err != nil {
+			die.Result = prior
+			return err
+		}
+		roll = die.Result.Value
+		base := prior.Value
+		if m.Penetrate {
+			base--
+		}
+		// the chained roll's own rerolls are kept in the history at the
+		// value they would have added to
+		history := append(prior.History, PriorResult{
+			Value:  prior.Value,
+			Kind:   kind,
+			Reason: m.String(),
+			Nonce:  prior.Nonce,
 		})
+		for _, reroll := range die.Result.History {
+			reroll.Value += base
+			history = append(history, reroll)
+		}
+		die.Result.History = history
+		die.Result.Value = base + roll
+		// criticals are determined by the first roll of the chain
+		die.Result.CritSuccess = prior.CritSuccess
+		die.Result.CritFailure = prior.CritFailure
+		if m.Once {
+			break
+		}
 	}
+	return nil
+}
 
+// applyRerolls applies a die's reroll modifiers until its value settles. They
+// are applied to the die's first roll by FullRoll, and to each chained roll of
+// a compounding or penetrating explosion.
+func (d *Die) applyRerolls(ctx context.Context) error {
+	for i := 0; i < len(d.Modifiers); i++ {
+		m, ok := d.Modifiers[i].(*RerollModifier)
+		if !ok {
+			continue
+		}
+		switch err := m.Apply(ctx, d); {
+		case err == ErrRerolled:
+			// restart from the first modifier
+			i = -1
+		case err != nil:
+			return err
+		}
+	}
 	return nil
 }
 
+// rerolledBy returns whether the die's current roll replaced a roll rerolled by
+// the modifier.
+func (d *Die) rerolledBy(m *RerollModifier) bool {
+	if d.Result == nil {
+		return false
+	}
+	reason := m.String()
+	for _, prior := range d.Result.History {
+		if prior.Kind == ReplacementReroll && prior.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns whether a roll of a die should explode. A target of 0 is the
+// die's maximum value.
+func (m *ExplodeModifier) match(die *Die, roll float64) (bool, bool) {
+	ct := *m.CompareTarget
+	if ct.Target == 0 {
+		_, hi := die.faceRange()
+		ct.Target = int(hi)
+	}
+	return ct.match(roll)
+}
+
+// Valid checks if the supplied die's roll is valid for an explosion, meaning
+// the die should explode.
 func (m *ExplodeModifier) Valid(ctx context.Context, r Roller) (bool, error) {
 	if m == nil {
 		return false, errors.New("nil modifier")
 	}
-	var (
-		result float64
-		err    error
-	)
-
-	result, err = r.Value(ctx)
+	die, ok := r.(*Die)
+	if !ok {
+		return false, errors.New("roller not a die")
+	}
+	result, err := die.Value(ctx)
 	if err != nil {
 		return false, err
 	}
-
-	if m.Compare == EMPTY {
-		m.Compare = EQL
-	}
-	switch m.Compare {
-	// until the comparison operation succeeds and the reroll passes, keep
-	// rerolling.
-	case EQL:
-		return result != float64(m.Target), nil
-	case LSS, LEQ:
-		return !(result <= float64(m.Target)), nil
-	case GTR, GEQ:
-		return !(result >= float64(m.Target)), nil
-	default:
-		err = &ErrNotImplemented{
+	explode, ok := m.match(die, result)
+	if !ok {
+		return false, &ErrNotImplemented{
 			fmt.Sprintf("uncaught case for exploding compare: %s", m.Compare),
 		}
-		return false, err
 	}
+	return explode, nil
 }
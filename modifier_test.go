@@ -1,11 +1,450 @@
 package dice
 
 import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
 	"testing"
 )
 
 var _ = Modifier(&RerollModifier{})
 var _ = Modifier(&DropKeepModifier{})
+var _ = Modifier(&CriticalSuccessModifier{})
+var _ = Modifier(&CriticalFailureModifier{})
+var _ = Modifier(&UniqueModifier{})
+var _ = Modifier(&ClampModifier{})
+var _ = Modifier(&MatchModifier{})
+
+func TestCriticalModifiers_Apply(t *testing.T) {
+	tests := []struct {
+		notation    string
+		value       float64
+		critSuccess bool
+		critFailure bool
+	}{
+		// defaults without modifiers
+		{"d20", 20, true, false},
+		{"d20", 1, false, true},
+		{"d20", 19, false, false},
+		{"d20cs>19", 19, true, false},
+		{"d20cs>19", 20, true, false},
+		{"d20cs>19", 18, false, false},
+		{"d20cs=19", 20, false, false},
+		{"d20cf<3", 3, false, true},
+		{"d20cf<3", 1, false, true},
+		{"d20cf<3", 20, true, false},
+		{"d20cf2", 1, false, false},
+		{"d20cs10cs20", 20, true, false},
+		{"d20cs10cs20", 10, true, false},
+		{"d20cs10cs20", 15, false, false},
+	}
+	for _, tt := range tests {
+		props, err := ParseNotation(context.Background(), tt.notation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		die := &Die{Type: props.Type, Size: props.Size, Modifiers: props.DieModifiers}
+		// roll as Die.Roll would, flagging the default criticals
+		die.Result = &Result{
+			Value:       tt.value,
+			CritSuccess: tt.value == float64(die.Size),
+			CritFailure: tt.value == 1,
+		}
+		for _, mod := range die.Modifiers {
+			if err := mod.Apply(context.Background(), die); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if die.CritSuccess != tt.critSuccess || die.CritFailure != tt.critFailure {
+			t.Errorf("%s rolling %v: crit success %v, crit failure %v; want %v, %v", tt.notation,
+				tt.value, die.CritSuccess, die.CritFailure, tt.critSuccess, tt.critFailure)
+		}
+	}
+}
+
+func TestExplodeModifier_Compound(t *testing.T) {
+	tests := []struct {
+		notation string
+		penalty  float64
+		maxChain int
+	}{
+		{"d6!!", 0, 0},
+		{"d6!p", 1, 0},
+		{"d6!!o", 0, 1},
+		{"d4!!>3", 0, 0},
+	}
+	ctx := context.WithValue(context.Background(), CtxKeySource, NewSeededSource(12))
+	for _, tt := range tests {
+		props, err := ParseNotation(ctx, tt.notation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mod := props.DieModifiers[0].(*ExplodeModifier)
+		var exploded bool
+		for i := 0; i < 500; i++ {
+			die := &Die{Type: props.Type, Size: props.Size, Modifiers: props.DieModifiers}
+			if err := die.FullRoll(ctx); err != nil {
+				t.Fatal(err)
+			}
+			history := die.Result.History
+			if tt.maxChain > 0 && len(history) > tt.maxChain {
+				t.Fatalf("%s: exploded %d times, want at most %d", tt.notation, len(history), tt.maxChain)
+			}
+			// each step of the chain must have been an exploding roll
+			prior := 0.0
+			for j, step := range history {
+				roll := step.Value - prior
+				if j > 0 {
+					roll += tt.penalty
+				}
+				if ok, _ := mod.match(die, roll); !ok {
+					t.Errorf("%s: roll %v in chain %+v did not explode", tt.notation, roll, history)
+				}
+				if step.Reason != mod.String() {
+					t.Errorf("%s: history reason %q, want %q", tt.notation, step.Reason, mod.String())
+				}
+				want := ReplacementCompound
+				if mod.Penetrate {
+					want = ReplacementPenetrate
+				}
+				if step.Kind != want {
+					t.Errorf("%s: history kind %q, want %q", tt.notation, step.Kind, want)
+				}
+				prior = step.Value
+				exploded = true
+			}
+			last := die.Result.Value - prior
+			if len(history) > 0 {
+				last += tt.penalty
+			}
+			if last < 1 || last > float64(die.Size) {
+				t.Errorf("%s: final roll %v out of range in %v", tt.notation, last, die)
+			}
+			if ok, _ := mod.match(die, last); ok && (tt.maxChain == 0 || len(history) < tt.maxChain) {
+				t.Errorf("%s: final roll %v should have exploded in %v", tt.notation, last, die)
+			}
+		}
+		if !exploded {
+			t.Errorf("%s: no die exploded", tt.notation)
+		}
+	}
+}
+
+func TestExplodeModifier_Compound_Impossible(t *testing.T) {
+	ctx := context.Background()
+	for _, notation := range []string{"d1!!", "d6!!>1", "d6!p<6"} {
+		props, err := ParseNotation(ctx, notation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		die := &Die{Type: props.Type, Size: props.Size, Modifiers: props.DieModifiers}
+		if err := die.FullRoll(ctx); err != ErrImpossibleRoll {
+			t.Errorf("%s: FullRoll() error = %v, want %v", notation, err, ErrImpossibleRoll)
+		}
+	}
+
+	// explosions count towards the maximum rolls
+	ctx = context.WithValue(ctx, CtxKeyMaxRolls, uint64(1))
+	var capped int
+	for i := 0; i < 50; i++ {
+		ctx := context.WithValue(ctx, CtxKeyTotalRolls, new(uint64))
+		die := &Die{Size: 2, Modifiers: ModifierList{
+			&ExplodeModifier{CompareTarget: &CompareTarget{EQL, 0}, Compound: true},
+		}}
+		switch err := die.FullRoll(ctx); err {
+		case nil:
+		case ErrMaxRolls:
+			capped++
+		default:
+			t.Fatalf("FullRoll() error = %v, want %v", err, ErrMaxRolls)
+		}
+	}
+	if capped == 0 {
+		t.Error("no explosion reached the maximum rolls")
+	}
+}
+
+func TestExplodeModifier_Apply(t *testing.T) {
+	tests := []struct {
+		notation string
+		count    int
+		once     bool
+	}{
+		{"4d6!", 4, false},
+		{"d6!", 1, false},
+		{"3d6!o", 3, true},
+		{"2d6!>5", 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.notation, func(t *testing.T) {
+			for seed := int64(0); seed < 200; seed++ {
+				ctx := context.WithValue(context.Background(), CtxKeySource, NewSeededSource(seed))
+				props, err := ParseNotation(ctx, tt.notation)
+				if err != nil {
+					t.Fatal(err)
+				}
+				mod := props.DieModifiers[0].(*ExplodeModifier)
+				group := MustNewRollerGroup(&props)
+				if err := group.FullRoll(ctx); err != nil {
+					t.Fatal(err)
+				}
+
+				// every exploding roll adds exactly one die to the group, so
+				// an exploding roll is never the group's last die
+				var explosions int
+				for i, r := range group.Group {
+					die := r.(*Die)
+					if die.Result == nil {
+						t.Fatalf("die %d of %v was not rolled", i, group)
+					}
+					if tt.once && i >= tt.count {
+						continue
+					}
+					if ok, _ := mod.match(die, die.Result.Value); ok {
+						explosions++
+					}
+				}
+				if want := tt.count + explosions; len(group.Group) != want {
+					t.Fatalf("seed %d: rolled %v, got %d dice, want %d", seed, group, len(group.Group), want)
+				}
+				last := group.Group[len(group.Group)-1].(*Die)
+				if ok, _ := mod.match(last, last.Result.Value); ok && !tt.once {
+					t.Errorf("seed %d: rolled %v, last die should have exploded", seed, group)
+				}
+
+				// new dice are part of the group's expression and encoding
+				if got := strings.Count(group.Expression(), "+") + 1; got != len(group.Group) {
+					t.Errorf("seed %d: expression %q has %d dice, want %d", seed, group.Expression(), got, len(group.Group))
+				}
+				b, err := json.Marshal(group)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := strings.Count(string(b), `"size":6`); got != len(group.Group) {
+					t.Errorf("seed %d: encoded %d dice, want %d", seed, got, len(group.Group))
+				}
+
+				// modifiers are not shared with the dice they explode from
+				for _, r := range group.Group[tt.count:] {
+					die := r.(*Die)
+					if tt.once {
+						if len(die.Modifiers) != 0 {
+							t.Errorf("seed %d: die exploded once has modifiers %v", seed, die.Modifiers)
+						}
+						continue
+					}
+					if len(die.Modifiers) != 1 || die.Modifiers[0] == mod ||
+						die.Modifiers[0].(*ExplodeModifier).CompareTarget == mod.CompareTarget {
+						t.Errorf("seed %d: exploded die shares modifiers %v", seed, die.Modifiers)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestClampModifier_Apply(t *testing.T) {
+	tests := []struct {
+		notation string
+		min, max float64
+	}{
+		{"d6min3", 3, 6},
+		{"d20max15", 1, 15},
+		{"d6min2max5", 2, 5},
+		// clamps apply after rerolls, regardless of their order
+		{"d6min3r3", 3, 6},
+	}
+	for _, tt := range tests {
+		var clamped bool
+		for seed := int64(0); seed < 100; seed++ {
+			ctx := context.WithValue(context.Background(), CtxKeySource, NewSeededSource(seed))
+			props, err := ParseNotation(ctx, tt.notation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			die := &Die{Type: props.Type, Size: props.Size, Modifiers: props.DieModifiers}
+			if err := die.FullRoll(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if v := die.Result.Value; v < tt.min || v > tt.max {
+				t.Fatalf("%s: rolled %v, want within [%v, %v]", tt.notation, v, tt.min, tt.max)
+			}
+			history := die.Result.History
+			if n := len(history); n > 0 && (history[n-1].Kind == ReplacementMin || history[n-1].Kind == ReplacementMax) {
+				clamped = true
+				if prior := history[n-1].Value; prior >= tt.min && prior <= tt.max {
+					t.Errorf("%s: clamped %v, which was within bounds", tt.notation, prior)
+				}
+				if strings.HasSuffix(tt.notation, "r3") && history[n-1].Value == 3 {
+					t.Errorf("%s: clamped a die before rerolling it: %v", tt.notation, die)
+				}
+			}
+		}
+		if !clamped {
+			t.Errorf("%s: no die was clamped", tt.notation)
+		}
+	}
+}
+
+func TestMatchModifier_Apply(t *testing.T) {
+	tests := []struct {
+		notation string
+		values   []float64
+		sets     []Set
+		pairs    int
+		str      string
+	}{
+		{"m", []float64{7, 4, 7, 1, 4, 7}, []Set{{Width: 3, Height: 7}, {Width: 2, Height: 4}}, 2, "7+4+7+1+4+7 => 30 (3×7, 2×4)"},
+		{"m", []float64{2, 9, 2, 9}, []Set{{Width: 2, Height: 9}, {Width: 2, Height: 2}}, 2, "2+9+2+9 => 22 (2×9, 2×2)"},
+		{"m3", []float64{7, 4, 7, 1, 4, 7}, []Set{{Width: 3, Height: 7}}, 2, "7+4+7+1+4+7 => 30 (3×7)"},
+		{"m", []float64{5, 5, 5, 5}, []Set{{Width: 4, Height: 5}}, 2, "5+5+5+5 => 20 (4×5)"},
+		{"m", []float64{1, 2, 3}, nil, 0, "1+2+3 => 6 (no sets)"},
+		{"kh2m", []float64{1, 1, 6, 3}, nil, 0, "0+0+6+3 => 9 (no sets)"},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		props, err := ParseNotation(ctx, "d10"+tt.notation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		group := &RollerGroup{Modifiers: props.GroupModifiers}
+		for _, v := range tt.values {
+			group.Add(&Die{Size: 10, Result: NewResult(v)})
+		}
+		for _, mod := range props.GroupModifiers {
+			if err := mod.Apply(ctx, group); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !reflect.DeepEqual(group.Sets, tt.sets) || group.Pairs != tt.pairs {
+			t.Errorf("%s on %v: sets %v and %d pairs, want %v and %d", tt.notation, tt.values,
+				group.Sets, group.Pairs, tt.sets, tt.pairs)
+		}
+		if got := group.String(); got != tt.str {
+			t.Errorf("%s on %v: String() = %q, want %q", tt.notation, tt.values, got, tt.str)
+		}
+	}
+
+	group := &RollerGroup{Modifiers: ModifierList{&MatchModifier{}}}
+	for _, v := range []float64{3, 3} {
+		group.Add(&Die{Size: 6, Result: NewResult(v)})
+	}
+	if err := group.Modifiers[0].Apply(ctx, group); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"sets":[{"width":2,"height":3}],"pairs":1`; !strings.Contains(string(b), want) {
+		t.Errorf("encoded %s, want it to contain %s", b, want)
+	}
+}
+
+func TestDropKeepModifier_String(t *testing.T) {
+	for _, notation := range []string{"kh3", "kl1", "dl1dh1", "km2", "d<3", "k>=5", "d=1", "u", "k2u"} {
+		props, err := ParseNotation(context.Background(), "6d6"+notation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := props.GroupModifiers.String(); got != notation {
+			t.Errorf("%s: String() = %q", notation, got)
+		}
+	}
+	if got := (&DropKeepModifier{Method: DropKeepMethodDrop, CompareTarget: &CompareTarget{EMPTY, 2}}).String(); got != "d=2" {
+		t.Errorf("String() = %q, want %q", got, "d=2")
+	}
+}
+
+func TestDropKeepModifier_Apply(t *testing.T) {
+	tests := []struct {
+		notation string
+		values   []float64
+		dropped  []bool
+	}{
+		{"kh2", []float64{3, 6, 1, 4}, []bool{true, false, true, false}},
+		{"dl1dh1", []float64{3, 6, 1, 4}, []bool{false, true, true, false}},
+		{"km2", []float64{3, 6, 1, 4}, []bool{false, true, true, false}},
+		{"km1", []float64{3, 6, 1, 4}, []bool{false, true, true, true}},
+		{"km3", []float64{5, 2}, []bool{false, false}},
+		{"dh5", []float64{5, 2}, []bool{true, true}},
+		{"d<3", []float64{3, 6, 1, 4}, []bool{true, false, true, false}},
+		{"k>=5", []float64{3, 6, 5, 4}, []bool{true, false, false, true}},
+		{"d1d=2", []float64{2, 5, 1}, []bool{true, false, true}},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		props, err := ParseNotation(ctx, "d6"+tt.notation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		group := &RollerGroup{}
+		for _, v := range tt.values {
+			group.Group = append(group.Group, &Die{Size: 6, Result: NewResult(v)})
+		}
+		for _, mod := range props.GroupModifiers {
+			if err := mod.Apply(ctx, group); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i, r := range group.Group {
+			if got := r.IsDropped(ctx); got != tt.dropped[i] {
+				t.Errorf("%s on %v: die %d dropped %v, want %v", tt.notation, tt.values, i, got, tt.dropped[i])
+			}
+		}
+	}
+}
+
+func TestUniqueModifier_Apply(t *testing.T) {
+	for seed := int64(0); seed < 100; seed++ {
+		ctx := context.WithValue(context.Background(), CtxKeySource, NewSeededSource(seed))
+		props, err := ParseNotation(ctx, "5d6u")
+		if err != nil {
+			t.Fatal(err)
+		}
+		group := MustNewRollerGroup(&props)
+		if err := group.FullRoll(ctx); err != nil {
+			t.Fatal(err)
+		}
+		seen := make(map[float64]bool)
+		for _, r := range group.Group {
+			v, _ := r.Value(ctx)
+			if seen[v] {
+				t.Fatalf("seed %d: %v has duplicate %v", seed, group, v)
+			}
+			seen[v] = true
+		}
+	}
+
+	// rerolled duplicates are rerolled again by the die's own modifiers
+	for seed := int64(0); seed < 100; seed++ {
+		ctx := context.WithValue(context.Background(), CtxKeySource, NewSeededSource(seed))
+		props, err := ParseNotation(ctx, "5d6r1u")
+		if err != nil {
+			t.Fatal(err)
+		}
+		group := MustNewRollerGroup(&props)
+		if err := group.FullRoll(ctx); err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range group.Group {
+			if v, _ := r.Value(ctx); v == 1 {
+				t.Fatalf("seed %d: %v kept a rerolled 1", seed, group)
+			}
+		}
+	}
+
+	for _, notation := range []string{"7d6u", "6d6r1u", "4d6min4u"} {
+		props, err := ParseNotation(context.Background(), notation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := MustNewRollerGroup(&props).FullRoll(context.Background()); err != ErrImpossibleRoll {
+			t.Errorf("%s: FullRoll() error = %v, want %v", notation, err, ErrImpossibleRoll)
+		}
+	}
+}
 
 func TestCompareOp_UnmarshalJSON(t *testing.T) {
 	type args struct {
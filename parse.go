@@ -12,7 +12,7 @@ import (
 var (
 	// DiceNotationPattern is the base XdY notation pattern for matching dice
 	// strings.
-	DiceNotationPattern = `(?i)(?P<count>\d+)?d(?P<size>\d{1,}|f|F)`
+	DiceNotationPattern = `(?i)(?P<count>\d+)?d(?P<size>\d{1,}(\{[^}]*\})?|f|F|%|\{[^}]*\})`
 
 	// DiceNotationRegex is the compiled RegEx for parsing supported dice
 	// notations.
@@ -32,171 +32,611 @@ var (
 var DiceWithModifiersExpressionRegex = regexp.MustCompile(
 	DiceNotationPattern + `(?P<modifiers>[!a-zA-Z=<>\d]*)`)
 
-// Modifier regexes.
-var (
-	rerollRegex   = regexp.MustCompile(`(?i)r(?P<once>o)?` + ComparePointPattern + `?`)
-	sortRegex     = regexp.MustCompile(`(?i)s(?P<sort>[ad])?`)
-	dropKeepRegex = regexp.MustCompile(`(?i)(?P<op>[dk][lh]?)(?P<num>\d+)?`)
-	criticalRegex = regexp.MustCompile(`(?i)c(?P<kind>[sf])` + ComparePointPattern)
-	explodeRegex  = regexp.MustCompile(`(?i)!` + ComparePointPattern + `?`)
-)
+// ParseNotation parses the provided dice notation into a RollerProperties
+// set, including any die and group modifiers. The notation must be a single
+// dice notation, like "3d6" or "4d6dl1": use ParseExpression to parse
+// arithmetic expressions of notations.
+func ParseNotation(ctx context.Context, notation string) (RollerProperties, error) {
+	if err := ctx.Err(); err != nil {
+		return RollerProperties{}, err
+	}
+	lex := newLexer(notation)
+	tok := lex.next()
+	if tok.kind != tokenNotation {
+		return RollerProperties{}, &ErrParseError{
+			Notation:     notation,
+			NotationElem: "notation",
+			ValueElem:    tok.text,
+			Message:      ": " + quote(tok.text) + " is not a dice notation",
+			Kind:         ParseErrorNotNotation,
+			Span:         tok.Span,
+			Suggestion:   "use a notation like \"3d6\" or \"4d6dl1\"",
+		}
+	}
+	if rest := lex.next(); rest.kind != tokenEOF {
+		extra := notation[rest.Start:]
+		return RollerProperties{}, &ErrParseError{
+			Notation:     notation,
+			NotationElem: "notation",
+			ValueElem:    extra,
+			Message:      ": unexpected " + quote(rest.text) + " after notation",
+			Kind:         ParseErrorUnexpectedToken,
+			Span:         Span{rest.Start, len(notation)},
+			Suggestion:   "remove " + quote(extra) + " or evaluate it as an expression",
+		}
+	}
+	return tok.props, tok.err
+}
 
-// Prefixes that indicate a modifier's start in a string
-const (
-	rerollPrefix   = "r"
-	sortPrefix     = "s"
-	dropPrefix     = "d"
-	keepPrefix     = "k"
-	criticalPrefix = "c"
-	explodePrefix  = "!"
-	compoundPrefix = "!!"
-)
+// A notationScanner reads the components of a single dice notation, within
+// bounds, out of a larger input string.
+type notationScanner struct {
+	input string
+	pos   int
+	end   int
+}
 
-// ParseNotation parses the provided notation with updated regular expressions
-// that also extract dice group modifiers.
-func ParseNotation(ctx context.Context, notation string) (RollerProperties, error) {
+// peek returns the lowercased byte n bytes ahead of the scanner's position, or
+// 0 if that is out of bounds.
+func (s *notationScanner) peek(n int) byte {
+	if s.pos+n >= s.end {
+		return 0
+	}
+	return lower(s.input[s.pos+n])
+}
+
+// accept advances the scanner if the next byte is c, and returns whether it
+// did so.
+func (s *notationScanner) accept(c byte) bool {
+	if s.peek(0) == c {
+		s.pos++
+		return true
+	}
+	return false
+}
+
+// integer scans an unsigned integer, if present. elem describes the integer's
+// purpose for error reporting.
+func (s *notationScanner) integer(elem string) (int, bool, error) {
+	start := s.pos
+	for isDigit(s.peek(0)) {
+		s.pos++
+	}
+	if start == s.pos {
+		return 0, false, nil
+	}
+	text := s.input[start:s.pos]
+	n, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, false, &ErrParseError{
+			Notation:     s.input,
+			NotationElem: elem,
+			ValueElem:    text,
+			Message:      ": invalid " + elem + " " + quote(text),
+			Kind:         ParseErrorInvalidNumber,
+			Span:         Span{start, s.pos},
+			Suggestion:   "use a smaller " + elem,
+		}
+	}
+	return n, true, nil
+}
+
+// missing returns an error for a required integer that is not present at the
+// scanner's position. elem describes the integer's purpose.
+func (s *notationScanner) missing(elem string) error {
+	err := &ErrParseError{
+		Notation:     s.input,
+		NotationElem: elem,
+		Message:      ": expected " + elem + ", found end of notation",
+		Kind:         ParseErrorUnexpectedEnd,
+		Span:         Span{s.pos, s.pos},
+		Suggestion:   "add a number for the " + elem + " to the end of the notation",
+	}
+	if s.pos < s.end {
+		found := s.input[s.pos : s.pos+1]
+		err.ValueElem = found
+		err.Message = ": expected " + elem + ", found " + quote(found)
+		err.Kind = ParseErrorUnexpectedToken
+		err.Span = Span{s.pos, s.pos + 1}
+		err.Suggestion = "insert a number for the " + elem + " before " + quote(found)
+	}
+	return err
+}
+
+// A listItem is an item of a braced list within a notation.
+type listItem struct {
+	text string
+	Span
+}
+
+// list scans a comma-separated list of elems up to and including the closing
+// brace. Items are trimmed of whitespace and must not be empty.
+func (s *notationScanner) list(elem string) ([]listItem, error) {
+	body := s.input[s.pos:s.end]
+	end := strings.IndexByte(body, '}')
+	if end < 0 {
+		return nil, &ErrParseError{
+			Notation:     s.input,
+			NotationElem: elem + "s",
+			Message:      ": expected \"}\" after " + elem + "s, found end of notation",
+			Kind:         ParseErrorUnexpectedEnd,
+			Span:         Span{s.end, s.end},
+			Suggestion:   "close the list of " + elem + "s with \"}\"",
+		}
+	}
+	var items []listItem
+	offset := s.pos
+	for _, part := range strings.Split(body[:end], ",") {
+		text := strings.TrimSpace(part)
+		if text == "" {
+			return nil, &ErrParseError{
+				Notation:     s.input,
+				NotationElem: elem,
+				ValueElem:    part,
+				Message:      ": empty " + elem + " in " + quote("{"+body[:end]+"}"),
+				Kind:         ParseErrorUnexpectedToken,
+				Span:         Span{offset, offset + len(part)},
+				Suggestion:   "add a value for each " + elem + ", separated by commas",
+			}
+		}
+		items = append(items, listItem{text, Span{offset, offset + len(part)}})
+		offset += len(part) + 1
+	}
+	s.pos += end + 1
+	return items, nil
+}
+
+// number parses a listed item as a number.
+func (s *notationScanner) number(elem string, item listItem) (float64, error) {
+	v, err := strconv.ParseFloat(item.text, 64)
+	if err != nil || !isNumber(item.text) {
+		return 0, &ErrParseError{
+			Notation:     s.input,
+			NotationElem: elem,
+			ValueElem:    item.text,
+			Message:      ": invalid " + elem + " " + quote(item.text),
+			Kind:         ParseErrorInvalidNumber,
+			Span:         item.Span,
+			Suggestion:   "use a decimal number for each " + elem,
+		}
+	}
+	return v, nil
+}
+
+// faces scans the faces of a custom die. Faces that are not numbers are
+// symbols.
+func (s *notationScanner) faces() ([]Face, error) {
+	items, err := s.list("face")
+	if err != nil {
+		return nil, err
+	}
+	faces := make([]Face, len(items))
+	for i, item := range items {
+		if !isNumber(item.text) {
+			faces[i] = Face{Symbol: item.text}
+			continue
+		}
+		if faces[i].Value, err = s.number("face", item); err != nil {
+			return nil, err
+		}
+	}
+	return faces, nil
+}
+
+// weights scans the weights of each of the faces of a weighted die.
+func (s *notationScanner) weights(faces int) ([]float64, error) {
+	start := s.pos - 1
+	items, err := s.list("weight")
+	if err != nil {
+		return nil, err
+	}
+	weights := make([]float64, len(items))
+	var total float64
+	for i, item := range items {
+		if weights[i], err = s.number("weight", item); err != nil {
+			return nil, err
+		}
+		if weights[i] < 0 {
+			return nil, &ErrParseError{
+				Notation:     s.input,
+				NotationElem: "weight",
+				ValueElem:    item.text,
+				Message:      ": negative weight " + quote(item.text),
+				Kind:         ParseErrorInvalidNumber,
+				Span:         item.Span,
+				Suggestion:   "use a weight of 0 for a face that is never rolled",
+			}
+		}
+		total += weights[i]
+	}
+	if len(weights) != faces || total == 0 {
+		list := s.input[start:s.pos]
+		return nil, &ErrParseError{
+			Notation:     s.input,
+			NotationElem: "weights",
+			ValueElem:    list,
+			Message: fmt.Sprintf(": %d weights %s for a die with %d faces",
+				len(weights), quote(list), faces),
+			Kind:       ParseErrorInvalidNumber,
+			Span:       Span{start, s.pos},
+			Suggestion: "give a weight for each face, at least one of them positive",
+		}
+	}
+	return weights, nil
+}
+
+// isNumber returns whether text is a decimal number with an optional sign.
+func isNumber(text string) bool {
+	if strings.HasPrefix(text, "+") || strings.HasPrefix(text, "-") {
+		text = text[1:]
+	}
+	digits, point := 0, false
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case isDigit(c):
+			digits++
+		case c == '.' && !point:
+			point = true
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
+
+// compare scans a comparison operator, if present. EMPTY is returned if there
+// is no operator.
+func (s *notationScanner) compare() CompareOp {
+	switch c := s.peek(0); c {
+	case '<', '>':
+		s.pos++
+		if s.accept('=') {
+			return LookupCompareOp(string(c) + "=")
+		}
+		return LookupCompareOp(string(c))
+	case '=':
+		s.pos++
+		return EQL
+	}
+	return EMPTY
+}
+
+// parseNotation parses the dice notation located at span within input.
+func parseNotation(input string, span Span) (RollerProperties, error) {
+	s := &notationScanner{input: input, pos: span.Start, end: span.End}
+	notation := input[span.Start:span.End]
 
 	props := RollerProperties{
+		Count:          1,
 		DieModifiers:   ModifierList{},
 		GroupModifiers: ModifierList{},
 	}
 
-	components := FindNamedCaptureGroups(DiceWithModifiersExpressionRegex, notation)
-
-	// Parse and cast dice properties from regex capture values
-	count64, err := strconv.ParseInt(components["count"], 10, 0)
-	count := int(count64)
+	// an explicit count of 0 should not be treated as an implied count of 1
+	count, ok, err := s.integer("count")
 	if err != nil {
-		// either there was an implied count, ex 'd20', or count was invalid.
-		// parsing "0dX" should not result in a count of 1.
-		count = 1
+		return props, err
 	}
-	props.Count = count
+	if ok {
+		props.Count = count
+	}
+	s.accept('d')
 
-	var size64 int64
-	if strings.EqualFold(components["size"], "f") {
+	if s.accept('f') {
 		props.Type = TypeFudge
-		props.Size = 1
-	} else if size64, err = strconv.ParseInt(components["size"], 10, 0); err != nil {
-		return props, &ErrParseError{notation, components["size"], "size", ": invalid size"}
-	} else if size64 < 0 {
-		return props, &ErrParseError{notation, components["size"], "size", ": invalid size"}
-	}
-	props.Size = int(size64)
-
-	// continuously loop through modifier string until we can't discern any more
-	// types. Once a modifier type is seen all modifiers of that type are
-	// searched out and added to the function sets in the order they appear in
-	// the string.
-	//
-	// There are circumstances where we have to discern potentially ambiguous
-	// modifier sets, like "2d6sdh" ("sort, drop highest" or "sort descending,
-	// unknown"?), so parsing should be left-to-right as with order of
-	// operations, and greedy
-	modifiers := components["modifiers"]
-	for modifiers != "" {
-		// check for context expiry
-		select {
-		default:
-		case <-ctx.Done():
-			panic(ctx.Err())
-		}
-		switch {
-		// rerolls
-		case strings.HasPrefix(modifiers, rerollPrefix):
-			remainingBytes := rerollRegex.ReplaceAllFunc([]byte(modifiers), func(matchBytes []byte) []byte {
-				captures := FindNamedCaptureGroups(rerollRegex, string(matchBytes))
-
-				point, _ := strconv.Atoi(captures["point"])
-				once := captures["once"] == "o"
-				props.DieModifiers = append(props.DieModifiers, &RerollModifier{
-					CompareTarget: &CompareTarget{
-						Compare: LookupCompareOp(captures["compare"]),
-						Target:  point,
-					},
-					Once: once,
-				})
-				return []byte{}
-			})
-			modifiers = string(remainingBytes)
-
-		// sort
-		case strings.HasPrefix(modifiers, sortPrefix):
-			remainingBytes := sortRegex.ReplaceAllFunc([]byte(modifiers), func(matchBytes []byte) []byte {
-				captures := FindNamedCaptureGroups(sortRegex, string(matchBytes))
-
-				mod := new(SortModifier)
-				switch captures["sort"] {
-				case "d":
-					mod.Direction = SortDirectionDescending
-				default:
-					mod.Direction = SortDirectionAscending
-				}
-
-				props.GroupModifiers = append(props.GroupModifiers, mod)
-				return []byte(nil)
-			})
-			modifiers = string(remainingBytes)
-
-		// drop/keep
-		case strings.HasPrefix(modifiers, dropPrefix), strings.HasPrefix(modifiers, keepPrefix):
-			remainingBytes := dropKeepRegex.ReplaceAllFunc([]byte(modifiers), func(matchBytes []byte) []byte {
-				captures := FindNamedCaptureGroups(dropKeepRegex, string(matchBytes))
-
-				var num int
-				if captures["num"] == "" {
-					num = 1
-				} else {
-					num, _ = strconv.Atoi(captures["num"])
-				}
-				props.GroupModifiers = append(props.GroupModifiers, &DropKeepModifier{
-					Method: DropKeepMethod(captures["op"]),
-					Num:    num,
-				})
-				return []byte(nil)
-			})
-			if modifiers == string(remainingBytes) {
-				fmt.Printf("invalid drop/keep: %s\n", modifiers)
-				modifiers = ""
-				break
+	} else if s.accept('%') {
+		props.Type = TypePercentile
+		props.Size = 100
+	} else if s.accept('{') {
+		faces, err := s.faces()
+		if err != nil {
+			return props, err
+		}
+		props.Type = TypeCustom
+		props.Size = len(faces)
+		props.Faces = faces
+	} else {
+		size, _, err := s.integer("size")
+		if err != nil {
+			return props, err
+		}
+		props.Size = size
+		if s.accept('{') {
+			weights, err := s.weights(size)
+			if err != nil {
+				return props, err
 			}
-			modifiers = string(remainingBytes)
-
-		// critical success/failure
-		case strings.HasPrefix(modifiers, criticalPrefix):
-			remainingBytes := criticalRegex.ReplaceAllFunc([]byte(modifiers), func(matchBytes []byte) []byte {
-				// TODO
-				// captures := getNamedCaptures(criticalRegex, string(matchBytes))
-
-				return []byte(nil)
-			})
-			modifiers = string(remainingBytes)
-
-		// case strings.HasPrefix(modifiers, compoundPrefix):
-
-		// explode
-		case strings.HasPrefix(modifiers, explodePrefix):
-			remainingBytes := explodeRegex.ReplaceAllFunc([]byte(modifiers), func(matchBytes []byte) []byte {
-				// TODO
-				captures := FindNamedCaptureGroups(explodeRegex, string(matchBytes))
-				point, _ := strconv.Atoi(captures["point"])
-				// once := captures["once"] == "o"
-				props.DieModifiers = append(props.DieModifiers, &ExplodeModifier{
-					CompareTarget: &CompareTarget{
-						Compare: LookupCompareOp(captures["compare"]),
-						Target:  point,
-					},
-					Once: false,
-				})
-
-				return []byte(nil)
-			})
-			modifiers = string(remainingBytes)
+			props.Type = TypeWeighted
+			props.Weights = weights
+		} else if _, base, ok := digitSize(size); ok && base == 6 {
+			// d66 and d666 are rolled as a d6 per digit
+			props.Type = TypeDigits
+		}
+	}
 
-		default:
-			fmt.Printf("invalid modifiers: %s\n", modifiers)
-			modifiers = ""
+	// Modifiers are parsed left-to-right and greedily, as with order of
+	// operations.
+	var unique, explode *Span
+	for s.pos < s.end {
+		start := s.pos
+		mod, group, err := parseModifier(s)
+		if err != nil {
+			return props, err
+		}
+		if mod == nil {
+			unknown := input[start:s.end]
+			return props, &ErrParseError{
+				Notation:     input,
+				NotationElem: "modifier",
+				ValueElem:    unknown,
+				Message:      ": unknown modifier " + quote(unknown) + " in " + quote(notation),
+				Kind:         ParseErrorUnknownModifier,
+				Span:         Span{start, s.end},
+				Suggestion: "remove " + quote(unknown) + "; supported modifiers are " +
+					strings.Join(supportedModifiers, ", "),
+			}
+		}
+		switch m := mod.(type) {
+		case *UniqueModifier:
+			unique = &Span{start, s.pos}
+		case *ExplodeModifier:
+			if !m.Compound && !m.Penetrate {
+				explode = &Span{start, s.pos}
+			}
+		}
+		if group {
+			props.GroupModifiers = append(props.GroupModifiers, mod)
+		} else {
+			props.DieModifiers = append(props.DieModifiers, mod)
+		}
+	}
+	// exploded dice join the group, so would need to be unique themselves
+	if unique != nil && explode != nil {
+		return props, &ErrParseError{
+			Notation:     input,
+			NotationElem: "modifier",
+			ValueElem:    input[unique.Start:unique.End],
+			Message: ": modifier " + quote(input[unique.Start:unique.End]) +
+				" cannot apply to exploding dice in " + quote(notation),
+			Kind:       ParseErrorUnknownModifier,
+			Span:       *unique,
+			Suggestion: "compound the explosion with \"!!\", or remove " + quote(input[explode.Start:explode.End]),
 		}
 	}
 	return props, nil
 }
+
+// parseListModifiers parses the modifiers located at span within input that
+// follow a roll list. Only drop/keep and sort modifiers can apply to a list's
+// items.
+func parseListModifiers(input string, span Span) (ModifierList, error) {
+	s := &notationScanner{input: input, pos: span.Start, end: span.End}
+	mods := ModifierList{}
+	for s.pos < s.end {
+		start := s.pos
+		mod, _, err := parseModifier(s)
+		if err != nil {
+			return nil, err
+		}
+		switch mod.(type) {
+		case *DropKeepModifier, *SortModifier:
+			mods = append(mods, mod)
+			continue
+		}
+		end := s.pos
+		if end == start {
+			end = s.end
+		}
+		unknown := input[start:end]
+		return nil, &ErrParseError{
+			Notation:     input,
+			NotationElem: "modifier",
+			ValueElem:    unknown,
+			Message:      ": modifier " + quote(unknown) + " cannot apply to a roll list",
+			Kind:         ParseErrorUnknownModifier,
+			Span:         Span{start, end},
+			Suggestion: "remove " + quote(unknown) + "; supported list modifiers are " +
+				strings.Join(listModifiers, ", "),
+		}
+	}
+	return mods, nil
+}
+
+// parseModifier parses the next modifier of a notation. It returns the
+// modifier and whether it is a group-level modifier. A nil modifier is
+// returned without advancing the scanner if the modifier is unknown.
+func parseModifier(s *notationScanner) (Modifier, bool, error) {
+	switch s.peek(0) {
+	// rerolls
+	case 'r':
+		s.pos++
+		once := s.accept('o')
+		compare := s.compare()
+		target, ok, err := s.integer("target")
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			return nil, false, s.missing("target")
+		}
+		if compare == EMPTY {
+			compare = EQL
+		}
+		return &RerollModifier{
+			CompareTarget: &CompareTarget{
+				Compare: compare,
+				Target:  target,
+			},
+			Once: once,
+		}, false, nil
+
+	// sort
+	case 's':
+		s.pos++
+		mod := &SortModifier{Direction: SortDirectionAscending}
+		switch s.peek(0) {
+		case 'a':
+			s.pos++
+		case 'd':
+			// "2d6sdh" is a sort followed by a drop highest, while "2d6sd" is
+			// a descending sort.
+			if !isDropKeepArg(s.peek(1)) {
+				s.pos++
+				mod.Direction = SortDirectionDescending
+			}
+		}
+		return mod, true, nil
+
+	// drop/keep
+	case 'd', 'k':
+		method := string(s.peek(0))
+		s.pos++
+		if c := s.peek(0); c == 'l' || c == 'h' || (c == 'm' && method == "k") {
+			method += string(c)
+			s.pos++
+		}
+		// drop/keep by comparison, like "d<3" or "k>=5"
+		if c := s.peek(0); len(method) == 1 && (c == '<' || c == '>' || c == '=') {
+			compare := s.compare()
+			target, ok, err := s.integer("target")
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok {
+				return nil, false, s.missing("target")
+			}
+			return &DropKeepModifier{
+				Method:        DropKeepMethod(method),
+				CompareTarget: &CompareTarget{Compare: compare, Target: target},
+			}, true, nil
+		}
+		num, ok, err := s.integer("number of dice")
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			num = 1
+		}
+		return &DropKeepModifier{
+			Method: DropKeepMethod(method),
+			Num:    num,
+		}, true, nil
+
+	// critical success/failure
+	case 'c':
+		kind := s.peek(1)
+		if kind != 's' && kind != 'f' {
+			return nil, false, nil
+		}
+		s.pos += 2
+		compare := s.compare()
+		target, ok, err := s.integer("target")
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			return nil, false, s.missing("target")
+		}
+		if compare == EMPTY {
+			compare = EQL
+		}
+		ct := &CompareTarget{Compare: compare, Target: target}
+		if kind == 's' {
+			return &CriticalSuccessModifier{ct}, false, nil
+		}
+		return &CriticalFailureModifier{ct}, false, nil
+
+	// clamps and matching
+	case 'm':
+		method := ClampMethod([]byte{s.peek(0), s.peek(1), s.peek(2)})
+		if method != ClampMethodMin && method != ClampMethodMax {
+			// matching sets
+			s.pos++
+			width, _, err := s.integer("minimum set width")
+			if err != nil {
+				return nil, false, err
+			}
+			return &MatchModifier{Min: width}, true, nil
+		}
+		s.pos += 3
+		target, ok, err := s.integer("target")
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			return nil, false, s.missing("target")
+		}
+		return &ClampModifier{Method: method, Target: target}, false, nil
+
+	// unique
+	case 'u':
+		s.pos++
+		return &UniqueModifier{}, true, nil
+
+	// success-counting pool
+	case '<', '>', '=':
+		compare := s.compare()
+		target, ok, err := s.integer("target number")
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			return nil, false, s.missing("target number")
+		}
+		return &SuccessModifier{&CompareTarget{Compare: compare, Target: target}}, true, nil
+	case 'f':
+		s.pos++
+		compare := s.compare()
+		target, ok, err := s.integer("failure target")
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			return nil, false, s.missing("failure target")
+		}
+		if compare == EMPTY {
+			compare = EQL
+		}
+		return &FailureModifier{&CompareTarget{Compare: compare, Target: target}}, true, nil
+
+	// explode
+	case '!':
+		s.pos++
+		compound := s.accept('!')
+		penetrate := s.accept('p')
+		once := s.accept('o')
+		compare := s.compare()
+		target, _, err := s.integer("target")
+		if err != nil {
+			return nil, false, err
+		}
+		return &ExplodeModifier{
+			CompareTarget: &CompareTarget{
+				Compare: compare,
+				Target:  target,
+			},
+			Once:      once,
+			Compound:  compound,
+			Penetrate: penetrate,
+		}, false, nil
+	}
+	return nil, false, nil
+}
+
+// supportedModifiers is the list of modifiers suggested when an unknown
+// modifier is encountered.
+var supportedModifiers = []string{
+	"r", "ro", "s", "sa", "sd", "d", "dl", "dh", "k", "kl", "kh", "km", "d<", "k>", "u", "min", "max", "m", "cs", "cf", "!", "!!", "!p", "!o", ">", "<", "=", "f",
+}
+
+// listModifiers is the list of modifiers suggested when a modifier that cannot
+// apply to a roll list is encountered.
+var listModifiers = []string{
+	"s", "sa", "sd", "d", "dl", "dh", "k", "kl", "kh", "km", "d<", "k>",
+}
+
+// isDropKeepArg returns whether c can directly follow a drop/keep modifier's
+// method character.
+func isDropKeepArg(c byte) bool {
+	return c == 'l' || c == 'h' || c == '<' || c == '>' || c == '=' || isDigit(c)
+}
@@ -64,7 +64,7 @@ func TestParseNotation(t *testing.T) {
 				Size:         20,
 				DieModifiers: ModifierList{},
 				GroupModifiers: []Modifier{
-					&DropKeepModifier{DropKeepMethodKeep, 1},
+					&DropKeepModifier{DropKeepMethodKeep, 1, nil},
 				},
 			},
 		},
@@ -77,7 +77,7 @@ func TestParseNotation(t *testing.T) {
 				Size:         20,
 				DieModifiers: ModifierList{},
 				GroupModifiers: []Modifier{
-					&DropKeepModifier{DropKeepMethodKeep, 1},
+					&DropKeepModifier{DropKeepMethodKeep, 1, nil},
 				},
 			},
 		},
@@ -90,7 +90,7 @@ func TestParseNotation(t *testing.T) {
 				Size:         20,
 				DieModifiers: ModifierList{},
 				GroupModifiers: []Modifier{
-					&DropKeepModifier{DropKeepMethodKeepLowest, 1},
+					&DropKeepModifier{DropKeepMethodKeepLowest, 1, nil},
 				},
 			},
 		},
@@ -103,7 +103,7 @@ func TestParseNotation(t *testing.T) {
 				Size:         20,
 				DieModifiers: ModifierList{},
 				GroupModifiers: []Modifier{
-					&DropKeepModifier{DropKeepMethodDrop, 1},
+					&DropKeepModifier{DropKeepMethodDrop, 1, nil},
 				},
 			},
 		},
@@ -116,7 +116,7 @@ func TestParseNotation(t *testing.T) {
 				Size:         20,
 				DieModifiers: ModifierList{},
 				GroupModifiers: []Modifier{
-					&DropKeepModifier{DropKeepMethodDrop, 1},
+					&DropKeepModifier{DropKeepMethodDrop, 1, nil},
 				},
 			},
 		},
@@ -129,7 +129,7 @@ func TestParseNotation(t *testing.T) {
 				Size:         20,
 				DieModifiers: ModifierList{},
 				GroupModifiers: []Modifier{
-					&DropKeepModifier{DropKeepMethodDropHighest, 1},
+					&DropKeepModifier{DropKeepMethodDropHighest, 1, nil},
 				},
 			},
 		},
@@ -141,7 +141,7 @@ func TestParseNotation(t *testing.T) {
 				Count: 3,
 				Size:  6,
 				DieModifiers: ModifierList{
-					&RerollModifier{Once: true, CompareTarget: &CompareTarget{EMPTY, 1}},
+					&RerollModifier{Once: true, CompareTarget: &CompareTarget{EQL, 1}},
 					&RerollModifier{CompareTarget: &CompareTarget{GTR, 3}},
 				},
 				GroupModifiers: ModifierList{},
@@ -156,35 +156,282 @@ func TestParseNotation(t *testing.T) {
 				Size:         6,
 				DieModifiers: ModifierList{},
 				GroupModifiers: ModifierList{
-					&DropKeepModifier{DropKeepMethodDrop, 1},
+					&DropKeepModifier{DropKeepMethodDrop, 1, nil},
 					&SortModifier{SortDirectionAscending},
 				},
 			},
 		},
 		{
-			name:     "valid-before-junk",
-			notation: "3d6sabcxyz3",
+			name:     "sort-then-drop-highest",
+			notation: "2d6sdh",
 			want: RollerProperties{
 				Type:         TypePolyhedron,
-				Count:        3,
+				Count:        2,
 				Size:         6,
 				DieModifiers: ModifierList{},
 				GroupModifiers: ModifierList{
 					&SortModifier{SortDirectionAscending},
+					&DropKeepModifier{DropKeepMethodDropHighest, 1, nil},
 				},
 			},
 		},
 		{
-			name:     "junk",
-			notation: "3d6abcxyz3sa",
+			name:     "sort-descending",
+			notation: "2d6sd",
+			want: RollerProperties{
+				Type:         TypePolyhedron,
+				Count:        2,
+				Size:         6,
+				DieModifiers: ModifierList{},
+				GroupModifiers: ModifierList{
+					&SortModifier{SortDirectionDescending},
+				},
+			},
+		},
+		{
+			name:     "zero-count",
+			notation: "0d6",
 			want: RollerProperties{
 				Type:           TypePolyhedron,
-				Count:          3,
+				Count:          0,
 				Size:           6,
 				DieModifiers:   ModifierList{},
 				GroupModifiers: ModifierList{},
 			},
 		},
+		{
+			name:     "critical-ranges",
+			notation: "d20cs>19cf<3cf=5",
+			want: RollerProperties{
+				Type:  TypePolyhedron,
+				Count: 1,
+				Size:  20,
+				DieModifiers: ModifierList{
+					&CriticalSuccessModifier{&CompareTarget{GTR, 19}},
+					&CriticalFailureModifier{&CompareTarget{LSS, 3}},
+					&CriticalFailureModifier{&CompareTarget{EQL, 5}},
+				},
+				GroupModifiers: ModifierList{},
+			},
+		},
+		{
+			name:     "critical-implied-equals",
+			notation: "d20cs18",
+			want: RollerProperties{
+				Type:  TypePolyhedron,
+				Count: 1,
+				Size:  20,
+				DieModifiers: ModifierList{
+					&CriticalSuccessModifier{&CompareTarget{EQL, 18}},
+				},
+				GroupModifiers: ModifierList{},
+			},
+		},
+		{
+			name:     "explosions",
+			notation: "3d6!!!p>5!!po!o",
+			want: RollerProperties{
+				Type:  TypePolyhedron,
+				Count: 3,
+				Size:  6,
+				DieModifiers: ModifierList{
+					&ExplodeModifier{CompareTarget: &CompareTarget{EMPTY, 0}, Compound: true},
+					&ExplodeModifier{CompareTarget: &CompareTarget{GTR, 5}, Penetrate: true},
+					&ExplodeModifier{CompareTarget: &CompareTarget{EMPTY, 0}, Compound: true, Penetrate: true, Once: true},
+					&ExplodeModifier{CompareTarget: &CompareTarget{EMPTY, 0}, Once: true},
+				},
+				GroupModifiers: ModifierList{},
+			},
+		},
+		{
+			name:     "success-pool",
+			notation: "8d6>4f1",
+			want: RollerProperties{
+				Type:         TypePolyhedron,
+				Count:        8,
+				Size:         6,
+				DieModifiers: ModifierList{},
+				GroupModifiers: ModifierList{
+					&SuccessModifier{&CompareTarget{GTR, 4}},
+					&FailureModifier{&CompareTarget{EQL, 1}},
+				},
+			},
+		},
+		{
+			name:     "success-pool-inclusive",
+			notation: "6d10>=8f<=2",
+			want: RollerProperties{
+				Type:         TypePolyhedron,
+				Count:        6,
+				Size:         10,
+				DieModifiers: ModifierList{},
+				GroupModifiers: ModifierList{
+					&SuccessModifier{&CompareTarget{GEQ, 8}},
+					&FailureModifier{&CompareTarget{LEQ, 2}},
+				},
+			},
+		},
+		{
+			name:     "keep-middle",
+			notation: "5d6km2",
+			want: RollerProperties{
+				Type:         TypePolyhedron,
+				Count:        5,
+				Size:         6,
+				DieModifiers: ModifierList{},
+				GroupModifiers: ModifierList{
+					&DropKeepModifier{Method: DropKeepMethodKeepMiddle, Num: 2},
+				},
+			},
+		},
+		{
+			name:     "drop-keep-compare",
+			notation: "6d6d<3k>=5u",
+			want: RollerProperties{
+				Type:         TypePolyhedron,
+				Count:        6,
+				Size:         6,
+				DieModifiers: ModifierList{},
+				GroupModifiers: ModifierList{
+					&DropKeepModifier{Method: DropKeepMethodDrop, CompareTarget: &CompareTarget{LSS, 3}},
+					&DropKeepModifier{Method: DropKeepMethodKeep, CompareTarget: &CompareTarget{GEQ, 5}},
+					&UniqueModifier{},
+				},
+			},
+		},
+		{
+			name:     "clamps",
+			notation: "4d6min2MAX5",
+			want: RollerProperties{
+				Type:  TypePolyhedron,
+				Count: 4,
+				Size:  6,
+				DieModifiers: ModifierList{
+					&ClampModifier{ClampMethodMin, 2},
+					&ClampModifier{ClampMethodMax, 5},
+				},
+				GroupModifiers: ModifierList{},
+			},
+		},
+		{
+			name:     "match",
+			notation: "8d10m3",
+			want: RollerProperties{
+				Type:         TypePolyhedron,
+				Count:        8,
+				Size:         10,
+				DieModifiers: ModifierList{},
+				GroupModifiers: ModifierList{
+					&MatchModifier{Min: 3},
+				},
+			},
+		},
+		{
+			name:     "custom",
+			notation: "3d{1, 1,2,-3.5}r1",
+			want: RollerProperties{
+				Type:  TypeCustom,
+				Count: 3,
+				Size:  4,
+				Faces: []Face{{Value: 1}, {Value: 1}, {Value: 2}, {Value: -3.5}},
+				DieModifiers: ModifierList{
+					&RerollModifier{CompareTarget: &CompareTarget{EQL, 1}},
+				},
+				GroupModifiers: ModifierList{},
+			},
+		},
+		{
+			name:     "custom-symbols",
+			notation: "d{Sword,0,+,Shield}",
+			want: RollerProperties{
+				Type:           TypeCustom,
+				Count:          1,
+				Size:           4,
+				Faces:          []Face{{Symbol: "Sword"}, {Value: 0}, {Symbol: "+"}, {Symbol: "Shield"}},
+				DieModifiers:   ModifierList{},
+				GroupModifiers: ModifierList{},
+			},
+		},
+		{
+			name:     "weighted",
+			notation: "2d6{1, 1,1,1,0.5,5}kh1",
+			want: RollerProperties{
+				Type:         TypeWeighted,
+				Count:        2,
+				Size:         6,
+				Weights:      []float64{1, 1, 1, 1, 0.5, 5},
+				DieModifiers: ModifierList{},
+				GroupModifiers: ModifierList{
+					&DropKeepModifier{Method: DropKeepMethodKeepHighest, Num: 1},
+				},
+			},
+		},
+		{
+			name:     "percentile",
+			notation: "2d%kh1",
+			want: RollerProperties{
+				Type:         TypePercentile,
+				Count:        2,
+				Size:         100,
+				DieModifiers: ModifierList{},
+				GroupModifiers: ModifierList{
+					&DropKeepModifier{Method: DropKeepMethodKeepHighest, Num: 1},
+				},
+			},
+		},
+		{
+			name:     "d66",
+			notation: "d66",
+			want: RollerProperties{
+				Type:           TypeDigits,
+				Count:          1,
+				Size:           66,
+				DieModifiers:   ModifierList{},
+				GroupModifiers: ModifierList{},
+			},
+		},
+		{
+			name:     "d666",
+			notation: "2D666",
+			want: RollerProperties{
+				Type:           TypeDigits,
+				Count:          2,
+				Size:           666,
+				DieModifiers:   ModifierList{},
+				GroupModifiers: ModifierList{},
+			},
+		},
+		{
+			name:     "not-digits",
+			notation: "d44",
+			want: RollerProperties{
+				Type:           TypePolyhedron,
+				Count:          1,
+				Size:           44,
+				DieModifiers:   ModifierList{},
+				GroupModifiers: ModifierList{},
+			},
+		},
+		{
+			name:     "expression",
+			notation: "2d6+1",
+			wantErr:  true,
+		},
+		{
+			name:     "not-notation",
+			notation: "floor",
+			wantErr:  true,
+		},
+		{
+			name:     "valid-before-junk",
+			notation: "3d6sabcxyz3",
+			wantErr:  true,
+		},
+		{
+			name:     "junk",
+			notation: "3d6abcxyz3sa",
+			wantErr:  true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -193,9 +440,65 @@ func TestParseNotation(t *testing.T) {
 				t.Errorf("ParseNotation() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if err != nil {
+				return
+			}
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("ParseNotation() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestParseNotation_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		notation string
+		kind     ParseErrorKind
+		span     Span
+		caret    string
+	}{
+		{"unknown-modifier", "3d6xyz", ParseErrorUnknownModifier, Span{3, 6}, "3d6xyz\n   ^^^"},
+		{"junk-after-modifier", "4d6dl1q", ParseErrorUnknownModifier, Span{6, 7}, "4d6dl1q\n      ^"},
+		{"expression", "2d6+1", ParseErrorUnexpectedToken, Span{3, 5}, "2d6+1\n   ^^"},
+		{"not-notation", "floor", ParseErrorNotNotation, Span{0, 5}, "floor\n^^^^^"},
+		{"critical-without-target", "d20cs>", ParseErrorUnexpectedEnd, Span{6, 6}, "d20cs>\n      ^"},
+		{"pool-without-target", "6d10>=", ParseErrorUnexpectedEnd, Span{6, 6}, ""},
+		{"failure-without-target", "6d10>7fx", ParseErrorUnexpectedToken, Span{7, 8}, "6d10>7fx\n       ^"},
+		{"reroll-without-target", "3d6r", ParseErrorUnexpectedEnd, Span{4, 4}, "3d6r\n    ^"},
+		{"reroll-compare-without-target", "3d6r<", ParseErrorUnexpectedEnd, Span{5, 5}, ""},
+		{"reroll-once-without-target", "3d6rok", ParseErrorUnexpectedToken, Span{5, 6}, "3d6rok\n     ^"},
+		{"drop-without-target", "4d6d<", ParseErrorUnexpectedEnd, Span{5, 5}, ""},
+		{"clamp-without-target", "4d6minx", ParseErrorUnexpectedToken, Span{6, 7}, ""},
+		{"unclosed-faces", "d{1,2", ParseErrorUnexpectedEnd, Span{5, 5}, ""},
+		{"empty-face", "d{1,,2}", ParseErrorUnexpectedToken, Span{4, 4}, ""},
+		{"weights-count", "d4{1,2,3}", ParseErrorInvalidNumber, Span{2, 9}, "d4{1,2,3}\n  ^^^^^^^"},
+		{"weights-zero", "d2{0,0}", ParseErrorInvalidNumber, Span{2, 7}, ""},
+		{"weight-negative", "d2{1,-1}", ParseErrorInvalidNumber, Span{5, 7}, ""},
+		{"weight-symbol", "d2{1,x}", ParseErrorInvalidNumber, Span{5, 6}, ""},
+		{"huge-size", "d99999999999999999999", ParseErrorInvalidNumber, Span{1, 21}, ""},
+		{"unique-exploding", "5d6!u", ParseErrorUnknownModifier, Span{4, 5}, "5d6!u\n    ^"},
+		{"exploding-unique", "5d6u!>5", ParseErrorUnknownModifier, Span{3, 4}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseNotation(context.Background(), tt.notation)
+			perr, ok := err.(*ErrParseError)
+			if !ok {
+				t.Fatalf("ParseNotation() error = %v, want *ErrParseError", err)
+			}
+			if perr.Kind != tt.kind {
+				t.Errorf("ParseNotation() error kind = %q, want %q", perr.Kind, tt.kind)
+			}
+			if perr.Span != tt.span {
+				t.Errorf("ParseNotation() error span = %v, want %v", perr.Span, tt.span)
+			}
+			if perr.Suggestion == "" {
+				t.Errorf("ParseNotation() error has no suggestion")
+			}
+			if tt.caret != "" && perr.Caret() != tt.caret {
+				t.Errorf("ErrParseError.Caret() = %q, want %q", perr.Caret(), tt.caret)
+			}
+		})
+	}
+}
@@ -0,0 +1,420 @@
+package dice
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// A parser is a recursive descent parser for dice expressions. The grammar it
+// implements, from lowest to highest precedence, is:
+//
+//	roll       = [ repeat ] expression
+//	repeat     = integer "x"
+//	expression = comparison [ "?" expression ":" expression ]
+//	comparison = sum [ ( "<" | "<=" | ">" | ">=" | "=" | "==" | "!=" ) sum ]
+//	sum        = term { ( "+" | "-" ) term }
+//	term       = unary { ( "*" | "/" | "%" ) unary }
+//	unary      = ( "+" | "-" ) unary | power
+//	power      = labeled [ ( "^" | "**" ) unary ]
+//	labeled    = primary [ label ]
+//	label      = "[" text "]"
+//	primary    = number | [ variable ] notation | variable | call | list | "(" expression ")"
+//	call       = identifier "(" [ expression { "," expression } ] ")"
+//	list       = "{" expression { "," expression } "}" [ modifiers ]
+type parser struct {
+	ctx   context.Context
+	input string
+	lex   *lexer
+	tok   token
+}
+
+// ParseExpression parses a dice expression into an abstract syntax tree. Dice
+// notations within the expression are parsed into RollerProperties, but no
+// dice are created or rolled.
+func ParseExpression(ctx context.Context, expression string) (Node, error) {
+	p := &parser{
+		ctx:   ctx,
+		input: expression,
+		lex:   &lexer{input: expression, comparisons: true},
+	}
+	p.advance()
+	node, err := p.parseRoll()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, p.unexpected("an operator")
+	}
+	return node, nil
+}
+
+// advance moves the parser to the next token.
+func (p *parser) advance() {
+	p.tok = p.lex.next()
+}
+
+// unexpected returns an error for the parser's current token, which is not
+// the expected element described.
+func (p *parser) unexpected(expected string) error {
+	err := &ErrParseError{
+		Notation:     p.input,
+		NotationElem: expected,
+		ValueElem:    p.tok.text,
+		Span:         p.tok.Span,
+	}
+	switch p.tok.kind {
+	case tokenEOF:
+		err.Kind = ParseErrorUnexpectedEnd
+		err.Message = ": expected " + expected + ", found end of expression"
+		err.Suggestion = "add " + expected + " to the end of the expression"
+	case tokenIllegal:
+		err.Kind = ParseErrorIllegalChar
+		err.Message = ": illegal character " + quote(p.tok.text)
+		err.Suggestion = "remove " + quote(p.tok.text)
+	default:
+		err.Kind = ParseErrorUnexpectedToken
+		err.Message = ": expected " + expected + ", found " + quote(p.tok.text)
+		err.Suggestion = "insert " + expected + " before " + quote(p.tok.text)
+	}
+	return err
+}
+
+// parseRoll parses an entire roll, which may be an expression repeated a
+// number of times.
+func (p *parser) parseRoll() (Node, error) {
+	tok := p.tok
+	if tok.kind != tokenRepeat {
+		return p.parseExpression()
+	}
+	count, err := strconv.Atoi(tok.text[:len(tok.text)-1])
+	if err != nil || count < 1 {
+		return nil, &ErrParseError{
+			Notation:     p.input,
+			NotationElem: "repetition",
+			ValueElem:    tok.text,
+			Message:      ": invalid repetition count " + quote(tok.text),
+			Kind:         ParseErrorInvalidNumber,
+			Span:         tok.Span,
+		}
+	}
+	p.advance()
+	x, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	return &RepeatNode{Span: Span{tok.Start, x.Pos().End}, Count: count, X: x}, nil
+}
+
+func (p *parser) parseExpression() (Node, error) {
+	cond, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenQuestion {
+		return cond, nil
+	}
+	p.advance()
+	then, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenColon {
+		return nil, p.unexpected(quote(":"))
+	}
+	p.advance()
+	els, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	return &ConditionalNode{
+		Span: Span{cond.Pos().Start, els.Pos().End},
+		Cond: cond,
+		Then: then,
+		Else: els,
+	}, nil
+}
+
+func (p *parser) parseComparison() (Node, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := binaryOperators[p.tok.kind]
+	if !ok || !op.IsComparison() {
+		return left, nil
+	}
+	p.advance()
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	return &BinaryNode{
+		Span:  Span{left.Pos().Start, right.Pos().End},
+		Op:    op,
+		Left:  left,
+		Right: right,
+	}, nil
+}
+
+func (p *parser) parseSum() (Node, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokenAdd || p.tok.kind == tokenSub {
+		op := binaryOperators[p.tok.kind]
+		p.advance()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryNode{
+			Span:  Span{left.Pos().Start, right.Pos().End},
+			Op:    op,
+			Left:  left,
+			Right: right,
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) parseTerm() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokenMul || p.tok.kind == tokenDiv || p.tok.kind == tokenMod {
+		op := binaryOperators[p.tok.kind]
+		p.advance()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryNode{
+			Span:  Span{left.Pos().Start, right.Pos().End},
+			Op:    op,
+			Left:  left,
+			Right: right,
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if p.tok.kind == tokenAdd || p.tok.kind == tokenSub {
+		start := p.tok.Start
+		op := binaryOperators[p.tok.kind]
+		p.advance()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryNode{
+			Span: Span{start, x.Pos().End},
+			Op:   op,
+			X:    x,
+		}, nil
+	}
+	return p.parsePower()
+}
+
+func (p *parser) parsePower() (Node, error) {
+	base, err := p.parseLabeled()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenPow {
+		return base, nil
+	}
+	p.advance()
+	// exponentiation is right-associative
+	exp, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &BinaryNode{
+		Span:  Span{base.Pos().Start, exp.Pos().End},
+		Op:    OpPow,
+		Left:  base,
+		Right: exp,
+	}, nil
+}
+
+func (p *parser) parseLabeled() (Node, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.tok
+	if tok.kind != tokenLabel {
+		return x, nil
+	}
+	if !strings.HasSuffix(tok.text, "]") {
+		return nil, &ErrParseError{
+			Notation:     p.input,
+			NotationElem: "label",
+			ValueElem:    tok.text,
+			Message:      ": unclosed label " + quote(tok.text),
+			Kind:         ParseErrorUnexpectedEnd,
+			Span:         Span{tok.End, tok.End},
+			Suggestion:   "add " + quote("]") + " to the end of the label",
+		}
+	}
+	label := strings.TrimSpace(tok.text[1 : len(tok.text)-1])
+	if label == "" {
+		return nil, &ErrParseError{
+			Notation:     p.input,
+			NotationElem: "label",
+			ValueElem:    tok.text,
+			Message:      ": empty label",
+			Kind:         ParseErrorUnexpectedToken,
+			Span:         tok.Span,
+			Suggestion:   "remove " + quote(tok.text),
+		}
+	}
+	p.advance()
+	return &LabelNode{Span: Span{x.Pos().Start, tok.End}, X: x, Label: label}, nil
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	if err := p.ctx.Err(); err != nil {
+		return nil, err
+	}
+	tok := p.tok
+	switch tok.kind {
+	case tokenNumber:
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, &ErrParseError{
+				Notation:     p.input,
+				NotationElem: "number",
+				ValueElem:    tok.text,
+				Message:      ": invalid number " + quote(tok.text),
+				Kind:         ParseErrorInvalidNumber,
+				Span:         tok.Span,
+			}
+		}
+		p.advance()
+		return &NumberNode{Span: tok.Span, Value: value}, nil
+	case tokenNotation:
+		if tok.err != nil {
+			return nil, tok.err
+		}
+		p.advance()
+		return &NotationNode{Span: tok.Span, Notation: tok.text, Properties: tok.props}, nil
+	case tokenVariable:
+		p.advance()
+		v := &VariableNode{Span: tok.Span, Name: tok.text[1:]}
+		// a variable followed by a notation without a count of its own is the
+		// notation's count, like "@level d6"
+		if p.tok.kind != tokenNotation || isDigit(p.tok.text[0]) {
+			return v, nil
+		}
+		notation := p.tok
+		if notation.err != nil {
+			return nil, notation.err
+		}
+		p.advance()
+		return &NotationNode{
+			Span:       Span{tok.Start, notation.End},
+			Notation:   notation.text,
+			Properties: notation.props,
+			Count:      v,
+		}, nil
+	case tokenIdent:
+		p.advance()
+		return p.parseCall(tok)
+	case tokenLParen:
+		p.advance()
+		x, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokenRParen {
+			return nil, p.unexpected(quote(")"))
+		}
+		end := p.tok.End
+		p.advance()
+		return &ParenNode{Span: Span{tok.Start, end}, X: x}, nil
+	case tokenLBrace:
+		p.advance()
+		return p.parseList(tok)
+	}
+	return nil, p.unexpected("an operand")
+}
+
+// parseCall parses the argument list of a function call, the name of which was
+// the previous token.
+func (p *parser) parseCall(name token) (Node, error) {
+	if p.tok.kind != tokenLParen {
+		return nil, p.unexpected(quote("("))
+	}
+	p.advance()
+	call := &CallNode{
+		Func: name.text,
+		Args: []Node{},
+	}
+	for p.tok.kind != tokenRParen {
+		if len(call.Args) > 0 {
+			if p.tok.kind != tokenComma {
+				return nil, p.unexpected(quote(",") + " or " + quote(")"))
+			}
+			p.advance()
+		}
+		arg, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, arg)
+	}
+	call.Span = Span{name.Start, p.tok.End}
+	p.advance()
+	return call, nil
+}
+
+// parseList parses the items of a braced list, the opening brace of which was
+// the previous token.
+func (p *parser) parseList(open token) (Node, error) {
+	list := &ListNode{Items: []Node{}}
+	for {
+		item, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, item)
+		if p.tok.kind != tokenComma {
+			break
+		}
+		p.advance()
+	}
+	if p.tok.kind != tokenRBrace {
+		return nil, p.unexpected(quote(",") + " or " + quote("}"))
+	}
+	end := p.tok.End
+	if mods := (Span{p.tok.Start + 1, end}); mods.End > mods.Start {
+		var err error
+		if list.Modifiers, err = parseListModifiers(p.input, mods); err != nil {
+			return nil, err
+		}
+	}
+	list.Span = Span{open.Start, end}
+	p.advance()
+	return list, nil
+}
+
+// binaryOperators maps operator tokens to their Operators.
+var binaryOperators = map[tokenKind]Operator{
+	tokenAdd: OpAdd,
+	tokenSub: OpSub,
+	tokenMul: OpMul,
+	tokenDiv: OpDiv,
+	tokenMod: OpMod,
+	tokenPow: OpPow,
+	tokenLss: OpLss,
+	tokenLeq: OpLeq,
+	tokenGtr: OpGtr,
+	tokenGeq: OpGeq,
+	tokenEql: OpEql,
+	tokenNeq: OpNeq,
+}
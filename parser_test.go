@@ -0,0 +1,190 @@
+package dice
+
+import (
+	"context"
+	"testing"
+)
+
+// ensure AST nodes implement Node
+var (
+	_ Node = (*NumberNode)(nil)
+	_ Node = (*NotationNode)(nil)
+	_ Node = (*UnaryNode)(nil)
+	_ Node = (*BinaryNode)(nil)
+	_ Node = (*ParenNode)(nil)
+	_ Node = (*CallNode)(nil)
+	_ Node = (*VariableNode)(nil)
+	_ Node = (*ListNode)(nil)
+	_ Node = (*RepeatNode)(nil)
+	_ Node = (*ConditionalNode)(nil)
+	_ Node = (*LabelNode)(nil)
+)
+
+func TestParseExpression(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       string
+		wantErr    bool
+	}{
+		{"number", "1", "1", false},
+		{"decimal", "0.5", "0.5", false},
+		{"notation", "3d6", "3d6", false},
+		{"notation-modifiers", "4d6dl1", "4d6dl1", false},
+		{"sum", "d20 + 5", "d20+5", false},
+		{"precedence", "1+2*3", "1+2*3", false},
+		{"unary", "-d4", "-d4", false},
+		{"power", "2^3**2", "2^3^2", false},
+		{"parens", "(1+2)*3", "(1+2)*3", false},
+		{"call", "max(d20, d20)", "max(d20,d20)", false},
+		{"nested-call", "floor(max(d20,2d12k1)/2+3)", "floor(max(d20,2d12k1)/2+3)", false},
+		{"variables", "d20 + @str_mod + @prof", "d20+@str_mod+@prof", false},
+		{"variable-count", "@level d6 + 1", "@level d6+1", false},
+		{"variable-counted", "@level 2d6", "", true},
+		{"bare-at", "d20 + @", "", true},
+		{"numeric-variable", "@1", "", true},
+		{"repeat", "6x 4d6kh3", "6x 4d6kh3", false},
+		{"repeat-expression", "2X(d20+5)", "2x (d20+5)", false},
+		{"list", "{d20 + 5, d20+5}", "{d20+5,d20+5}", false},
+		{"nested-list", "{d6, {d4, 1}}*2", "{d6,{d4,1}}*2", false},
+		{"repeat-zero", "0x d6", "", true},
+		{"repeat-nested", "2x 3x d6", "", true},
+		{"repeat-inner", "1 + 2x d6", "", true},
+		{"empty-list", "{}", "", true},
+		{"unclosed-list", "{d20, d20", "", true},
+		{"grouped", "{1d20+5, 1d12+3}kh1", "{1d20+5,1d12+3}kh1", false},
+		{"grouped-sort", "{3d6, 2d8}sddl1 * 2", "{3d6,2d8}sddl1*2", false},
+		{"grouped-compare", "{d20, d4}k>10", "{d20,d4}k>10", false},
+		{"grouped-reroll", "{d20, d4}r1", "", true},
+		{"comparison", "d20+5 >= 15", "d20+5 >= 15", false},
+		{"comparison-notation", "d20 >= 15", "d20 >= 15", false},
+		{"comparison-modifier", "d20>=15", "d20 >= 15", false},
+		{"comparison-counted", "1d20=20 ? 2d6 : 0", "1d20 = 20 ? 2d6 : 0", false},
+		{"comparison-modifiers", "d20r1>=15", "d20r1 >= 15", false},
+		{"comparison-inclusive-gtr", "d20>15", "d20 >= 15", false},
+		{"comparison-inclusive-lss", "d20<5 ? 1 : 0", "d20 <= 5 ? 1 : 0", false},
+		{"variable-success-pool", "@n d6>4", "@n d6>4", false},
+		{"success-pool", "3d6>4", "3d6>4", false},
+		{"success-failure-pool", "d20>=15f1", "d20>=15f1", false},
+		{"comparisons", "(1<2) + (1<=2) + (1>2) + (1==2) + (1=2) + (1!=2)", "(1 < 2)+(1 <= 2)+(1 > 2)+(1 = 2)+(1 = 2)+(1 != 2)", false},
+		{"conditional", "d20 >= 15 ? 2d6 : 0", "d20 >= 15 ? 2d6 : 0", false},
+		{"nested-conditional", "d20 = 20 ? 2d6 : d20 > 10 ? d6 : 0", "d20 = 20 ? 2d6 : d20 > 10 ? d6 : 0", false},
+		{"repeat-comparison", "3x d20+5 >= 15", "3x d20+5 >= 15", false},
+		{"chained-comparison", "1 < 2 < 3", "", true},
+		{"missing-else", "d20 > 10 ? 1", "", true},
+		{"bang", "d20 ! 2", "", true},
+		{"labels", "1d8[slashing] + 2d6 [fire]", "1d8[slashing]+2d6[fire]", false},
+		{"label-term", "(2d6+3)[ fire ]*2", "(2d6+3)[fire]*2", false},
+		{"label-list", "{d20[a], d20[b]}kh1", "{d20[a],d20[b]}kh1", false},
+		{"unclosed-label", "d6[fire", "", true},
+		{"empty-label", "d6[ ]", "", true},
+		{"double-label", "d6[a][b]", "", true},
+		{"bare-label", "[fire]", "", true},
+		{"empty", "", "", true},
+		{"trailing-operator", "d20+", "", true},
+		{"unclosed", "(1+2", "", true},
+		{"illegal", "d20 $ 3", "", true},
+		{"dangling-ident", "floor", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseExpression(context.Background(), tt.expression)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseExpression() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.String() != tt.want {
+				t.Errorf("ParseExpression() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExpression_Span(t *testing.T) {
+	node, err := ParseExpression(context.Background(), "1 + 2d6kh1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin, ok := node.(*BinaryNode)
+	if !ok {
+		t.Fatalf("got %T, want *BinaryNode", node)
+	}
+	if want := (Span{0, 10}); bin.Pos() != want {
+		t.Errorf("BinaryNode span = %v, want %v", bin.Pos(), want)
+	}
+	notation, ok := bin.Right.(*NotationNode)
+	if !ok {
+		t.Fatalf("got %T, want *NotationNode", bin.Right)
+	}
+	if want := (Span{4, 10}); notation.Pos() != want {
+		t.Errorf("NotationNode span = %v, want %v", notation.Pos(), want)
+	}
+	if notation.Properties.Count != 2 || notation.Properties.Size != 6 {
+		t.Errorf("NotationNode properties = %+v", notation.Properties)
+	}
+}
+
+func TestParseExpression_VariableCount(t *testing.T) {
+	node, err := ParseExpression(context.Background(), "1 + @level d8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notation, ok := node.(*BinaryNode).Right.(*NotationNode)
+	if !ok {
+		t.Fatalf("got %T, want *NotationNode", node.(*BinaryNode).Right)
+	}
+	if want := (Span{4, 13}); notation.Pos() != want {
+		t.Errorf("NotationNode span = %v, want %v", notation.Pos(), want)
+	}
+	if notation.Count == nil || notation.Count.Name != "level" {
+		t.Fatalf("NotationNode count = %v, want @level", notation.Count)
+	}
+	ctx := context.WithValue(context.Background(), CtxKeyParameters, map[string]interface{}{"level": 3})
+	props, err := notation.Resolve(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if props.Count != 3 || props.Size != 8 {
+		t.Errorf("resolved properties = %+v", props)
+	}
+	if notation.Properties.Count == 3 {
+		t.Errorf("Resolve() modified the notation's properties")
+	}
+}
+
+func TestParseExpression_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		kind       ParseErrorKind
+		span       Span
+	}{
+		{"empty", "", ParseErrorUnexpectedEnd, Span{0, 0}},
+		{"trailing-operator", "d20 +", ParseErrorUnexpectedEnd, Span{5, 5}},
+		{"illegal", "d20 $ 3", ParseErrorIllegalChar, Span{4, 5}},
+		{"missing-operator", "d20 3", ParseErrorUnexpectedToken, Span{4, 5}},
+		{"unclosed", "(1+2", ParseErrorUnexpectedEnd, Span{4, 4}},
+		{"unknown-modifier", "1 + 3d6xyz", ParseErrorUnknownModifier, Span{7, 10}},
+		{"repeat-zero", "0x d6", ParseErrorInvalidNumber, Span{0, 2}},
+		{"unclosed-list", "{d20, d20", ParseErrorUnexpectedEnd, Span{9, 9}},
+		{"list-modifier", "{d20, d4}kh1!", ParseErrorUnknownModifier, Span{12, 13}},
+		{"unclosed-label", "d6[fire + 1", ParseErrorUnexpectedEnd, Span{11, 11}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseExpression(context.Background(), tt.expression)
+			perr, ok := err.(*ErrParseError)
+			if !ok {
+				t.Fatalf("ParseExpression() error = %v, want *ErrParseError", err)
+			}
+			if perr.Kind != tt.kind {
+				t.Errorf("ParseExpression() error kind = %q, want %q", perr.Kind, tt.kind)
+			}
+			if perr.Span != tt.span {
+				t.Errorf("ParseExpression() error span = %v, want %v", perr.Span, tt.span)
+			}
+		})
+	}
+}
@@ -0,0 +1,734 @@
+package probability
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/travis-g/dice"
+	dicemath "github.com/travis-g/dice/math"
+)
+
+// DefaultExplodeDepth is the maximum number of times an exploding die's
+// explosions are followed when using Calculate.
+var DefaultExplodeDepth = 10
+
+// ErrUnsupported is returned when an expression cannot be analyzed exactly.
+// Such expressions can still be evaluated, and sampled by simulation.
+var ErrUnsupported = errors.New("unsupported by exact analysis")
+
+// A DieDistribution is a function that returns the distribution of the face
+// value of a single, unmodified die described by a properties list.
+type DieDistribution func(*dice.RollerProperties) (*Distribution, error)
+
+// DieDistributions is the package-wide mapping of die types and the functions
+// used to compute their distributions. As with dice.RollerFactoryMap, this map
+// can be modified to support new die types.
+var DieDistributions = map[dice.DieType]DieDistribution{
+	dice.TypePolyhedron: polyhedronDistribution,
+	dice.TypeFudge:      fudgeDistribution,
+	dice.TypeCustom:     customDistribution,
+	dice.TypeWeighted:   weightedDistribution,
+	dice.TypePercentile: polyhedronDistribution,
+	dice.TypeDigits:     digitsDistribution,
+}
+
+func polyhedronDistribution(props *dice.RollerProperties) (*Distribution, error) {
+	if props.Size == 0 {
+		return Constant(0), nil
+	}
+	faces := make([]float64, props.Size)
+	for i := range faces {
+		faces[i] = float64(i + 1)
+	}
+	return Uniform(faces...), nil
+}
+
+func fudgeDistribution(props *dice.RollerProperties) (*Distribution, error) {
+	// as with dice.NewDie, a fudge die of size 0 is a standard fudge die
+	size := props.Size
+	if size == 0 {
+		size = 1
+	}
+	faces := make([]float64, 0, 2*size+1)
+	for i := -size; i <= size; i++ {
+		faces = append(faces, float64(i))
+	}
+	return Uniform(faces...), nil
+}
+
+func customDistribution(props *dice.RollerProperties) (*Distribution, error) {
+	faces := make([]float64, len(props.Faces))
+	for i, f := range props.Faces {
+		if f.Symbol != "" {
+			return nil, errors.Wrapf(ErrUnsupported, "symbol face %q", f.Symbol)
+		}
+		faces[i] = f.Value
+	}
+	if props.Weights != nil {
+		return weighted(faces, props.Weights)
+	}
+	return Uniform(faces...), nil
+}
+
+// digitsDistribution is the distribution of a digit die, like a d66, whose
+// digits are each rolled on a die numbered 1 to the repeated digit.
+func digitsDistribution(props *dice.RollerProperties) (*Distribution, error) {
+	// validate the size as the die's factory would
+	if _, err := dice.NewRoller(&dice.RollerProperties{Type: dice.TypeDigits, Size: props.Size}); err != nil {
+		return nil, err
+	}
+	digits, base := len(strconv.Itoa(props.Size)), props.Size%10
+	values := []float64{0}
+	for j := 0; j < digits; j++ {
+		next := make([]float64, 0, len(values)*base)
+		for _, v := range values {
+			for face := 1; face <= base; face++ {
+				next = append(next, v*10+float64(face))
+			}
+		}
+		values = next
+	}
+	return Uniform(values...), nil
+}
+
+func weightedDistribution(props *dice.RollerProperties) (*Distribution, error) {
+	faces := make([]float64, props.Size)
+	for i := range faces {
+		faces[i] = float64(i + 1)
+	}
+	return weighted(faces, props.Weights)
+}
+
+// weighted returns the distribution of a die whose faces have the given
+// relative weights.
+func weighted(faces, weights []float64) (*Distribution, error) {
+	if len(weights) != len(faces) {
+		return nil, errors.Errorf("%d weights for a die with %d faces", len(weights), len(faces))
+	}
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		return nil, errors.New("weighted die has no face with a positive weight")
+	}
+	d := NewDistribution()
+	for i, v := range faces {
+		d.add(v, weights[i]/total)
+	}
+	return d, nil
+}
+
+// A Calculator computes exact distributions of dice expressions.
+type Calculator struct {
+	// ExplodeDepth is the maximum number of times an exploding die's
+	// explosions are followed.
+	ExplodeDepth int
+}
+
+// Calculate computes the distribution of a dice expression using the default
+// explosion depth.
+func Calculate(ctx context.Context, expression string) (*Distribution, error) {
+	c := &Calculator{ExplodeDepth: DefaultExplodeDepth}
+	return c.Expression(ctx, expression)
+}
+
+// Expression parses and computes the distribution of a dice expression.
+func (c *Calculator) Expression(ctx context.Context, expression string) (*Distribution, error) {
+	node, err := dice.ParseExpression(ctx, expression)
+	if err != nil {
+		return nil, err
+	}
+	return c.Node(ctx, node)
+}
+
+// Node computes the distribution of a parsed dice expression.
+func (c *Calculator) Node(ctx context.Context, node dice.Node) (*Distribution, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	switch n := node.(type) {
+	case *dice.NumberNode:
+		return Constant(n.Value), nil
+	case *dice.NotationNode:
+		props, err := n.Resolve(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return c.Notation(ctx, &props)
+	case *dice.VariableNode:
+		v, err := n.Value(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return Constant(v), nil
+	case *dice.ParenNode:
+		return c.Node(ctx, n.X)
+	case *dice.LabelNode:
+		return c.Node(ctx, n.X)
+	case *dice.ListNode:
+		items := make([]*Distribution, len(n.Items))
+		for i, item := range n.Items {
+			x, err := c.Node(ctx, item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = x
+		}
+		return c.list(items, n.Modifiers)
+	case *dice.RepeatNode:
+		// the result of a repeated roll is the sum of its rolls
+		x, err := c.Node(ctx, n.X)
+		if err != nil {
+			return nil, err
+		}
+		return sum(x, n.Count), nil
+	case *dice.UnaryNode:
+		x, err := c.Node(ctx, n.X)
+		if err != nil {
+			return nil, err
+		}
+		switch n.Op {
+		case dice.OpAdd:
+			return x, nil
+		case dice.OpSub:
+			return x.Map(func(v float64) float64 { return -v }), nil
+		}
+		return nil, fmt.Errorf("unknown unary operator %q", n.Op)
+	case *dice.BinaryNode:
+		left, err := c.Node(ctx, n.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := c.Node(ctx, n.Right)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := n.Op.Eval(0, 0); err != nil {
+			return nil, err
+		}
+		return Combine(left, right, func(x, y float64) float64 {
+			v, _ := n.Op.Eval(x, y)
+			return v
+		}), nil
+	case *dice.ConditionalNode:
+		cond, err := c.Node(ctx, n.Cond)
+		if err != nil {
+			return nil, err
+		}
+		then, err := c.Node(ctx, n.Then)
+		if err != nil {
+			return nil, err
+		}
+		els, err := c.Node(ctx, n.Else)
+		if err != nil {
+			return nil, err
+		}
+		return Mix(1-cond.Probability(0), then, els), nil
+	case *dice.CallNode:
+		return c.call(ctx, n)
+	}
+	return nil, errors.Wrapf(ErrUnsupported, "node %s", node)
+}
+
+// call computes the distribution of a function call by calling the function
+// with every combination of its arguments' outcomes.
+func (c *Calculator) call(ctx context.Context, n *dice.CallNode) (*Distribution, error) {
+	f, ok := dicemath.DiceFunctions[n.Func]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", n.Func)
+	}
+
+	// build the joint distribution of the arguments
+	type tuple struct {
+		args []interface{}
+		p    float64
+	}
+	tuples := []tuple{{args: []interface{}{}, p: 1}}
+	for _, arg := range n.Args {
+		d, err := c.Node(ctx, arg)
+		if err != nil {
+			return nil, err
+		}
+		next := make([]tuple, 0, len(tuples)*d.Len())
+		for _, t := range tuples {
+			for _, o := range d.Outcomes() {
+				args := make([]interface{}, len(t.args), len(t.args)+1)
+				copy(args, t.args)
+				next = append(next, tuple{append(args, o.Value), t.p * o.Probability})
+			}
+		}
+		tuples = next
+	}
+
+	out := NewDistribution()
+	for _, t := range tuples {
+		result, err := f(t.args...)
+		if err != nil {
+			return nil, err
+		}
+		v, ok := result.(float64)
+		if !ok {
+			return nil, fmt.Errorf("result %v not a float", result)
+		}
+		out.add(v, t.p)
+	}
+	return out, nil
+}
+
+// maxListOutcomes is the maximum number of combinations of item outcomes
+// enumerated to find the distribution of a grouped list.
+const maxListOutcomes = 1 << 20
+
+// list computes the distribution of the total of a roll list's items, the
+// distributions of which are independent but not necessarily alike, after
+// applying the list's modifiers.
+func (c *Calculator) list(items []*Distribution, mods dice.ModifierList) (*Distribution, error) {
+	// items dropped by comparison are dropped independently of the others, so
+	// score nothing
+	positional := mods[:0:0]
+	var compared []*dice.DropKeepModifier
+	for _, mod := range mods {
+		if m, ok := mod.(*dice.DropKeepModifier); ok && m.CompareTarget != nil {
+			compared = append(compared, m)
+			continue
+		}
+		positional = append(positional, mod)
+	}
+	kept, err := keptPositions(len(items), positional)
+	if err != nil {
+		return nil, err
+	}
+	total := Constant(0)
+	allKept := true
+	for _, k := range kept {
+		allKept = allKept && k
+	}
+	if allKept {
+		for _, item := range items {
+			total = Add(total, item.Map(func(v float64) float64 {
+				for _, m := range compared {
+					if matches(m.CompareTarget, 0, v) != (m.Method == dice.DropKeepMethodKeep) {
+						return 0
+					}
+				}
+				return v
+			}))
+		}
+		return total, nil
+	}
+	if len(compared) > 0 {
+		return nil, errors.Wrapf(ErrUnsupported, "drop/keep by comparison with %s", positional)
+	}
+
+	// which items are kept depends on all of their values, so every
+	// combination of outcomes is enumerated
+	combinations := 1
+	for _, item := range items {
+		combinations *= len(item.Outcomes())
+		if combinations > maxListOutcomes {
+			return nil, errors.Wrapf(ErrUnsupported, "list with more than %d combinations of outcomes", maxListOutcomes)
+		}
+	}
+	p := make(map[float64]float64)
+	values := make([]float64, len(items))
+	sorted := make([]float64, len(items))
+	var enumerate func(i int, prob float64)
+	enumerate = func(i int, prob float64) {
+		if i == len(items) {
+			copy(sorted, values)
+			sort.Float64s(sorted)
+			var sum float64
+			for j, v := range sorted {
+				if kept[j] {
+					sum += v
+				}
+			}
+			p[sum] += prob
+			return
+		}
+		for _, o := range items[i].Outcomes() {
+			values[i] = o.Value
+			enumerate(i+1, prob*o.Probability)
+		}
+	}
+	enumerate(0, 1)
+	return &Distribution{p: p}, nil
+}
+
+// Notation computes the distribution of the total of the group of dice
+// described by a properties list.
+func (c *Calculator) Notation(ctx context.Context, props *dice.RollerProperties) (*Distribution, error) {
+	if props.Count == 0 {
+		return Constant(0), nil
+	}
+	die, err := c.Die(ctx, props)
+	if err != nil {
+		return nil, err
+	}
+
+	// the dice of a success-counting pool each score a success, failure, or
+	// nothing, rather than their value
+	var success, failure []*dice.CompareTarget
+	mods := make(dice.ModifierList, 0, len(props.GroupModifiers))
+	for _, mod := range props.GroupModifiers {
+		switch m := mod.(type) {
+		case *dice.SuccessModifier:
+			success = append(success, m.CompareTarget)
+		case *dice.FailureModifier:
+			failure = append(failure, m.CompareTarget)
+		default:
+			mods = append(mods, mod)
+		}
+	}
+	if len(success) > 0 || len(failure) > 0 {
+		if len(mods) > 0 {
+			return nil, errors.Wrapf(ErrUnsupported, "success-counting pool with %s", mods)
+		}
+		for _, mod := range props.DieModifiers {
+			if m, ok := mod.(*dice.ExplodeModifier); ok && !m.Compound && !m.Penetrate {
+				return nil, errors.Wrapf(ErrUnsupported, "success-counting pool of exploding dice")
+			}
+		}
+		score := func(v float64) float64 {
+			for _, ct := range failure {
+				if matches(ct, 0, v) {
+					return -1
+				}
+			}
+			for _, ct := range success {
+				if matches(ct, 0, v) {
+					return 1
+				}
+			}
+			return 0
+		}
+		return sum(die.Map(score), props.Count), nil
+	}
+
+	// dice dropped by comparison are dropped independently of the others, so
+	// score nothing
+	positional := mods[:0:0]
+	var compared []*dice.DropKeepModifier
+	for _, mod := range mods {
+		if m, ok := mod.(*dice.DropKeepModifier); ok && m.CompareTarget != nil {
+			compared = append(compared, m)
+			continue
+		}
+		positional = append(positional, mod)
+	}
+	if len(compared) > 0 {
+		for _, mod := range positional {
+			switch mod.(type) {
+			case *dice.SortModifier, *dice.MatchModifier:
+			default:
+				return nil, errors.Wrapf(ErrUnsupported, "drop/keep by comparison with %s", mod)
+			}
+		}
+		for _, mod := range props.DieModifiers {
+			if m, ok := mod.(*dice.ExplodeModifier); ok && !m.Compound && !m.Penetrate {
+				return nil, errors.Wrapf(ErrUnsupported, "exploding dice with drop/keep %s", props.GroupModifiers)
+			}
+		}
+		return sum(die.Map(func(v float64) float64 {
+			for _, m := range compared {
+				if matches(m.CompareTarget, 0, v) != (m.Method == dice.DropKeepMethodKeep) {
+					return 0
+				}
+			}
+			return v
+		}), props.Count), nil
+	}
+
+	kept, err := keptPositions(props.Count, mods)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range kept {
+		if k {
+			continue
+		}
+		// exploded dice join the group as separate dice, so which dice are
+		// kept depends on how many dice exploded. Compounding and penetrating
+		// explosions add to the die's own value, so are unaffected.
+		for _, mod := range props.DieModifiers {
+			if m, ok := mod.(*dice.ExplodeModifier); ok && !m.Compound && !m.Penetrate {
+				return nil, errors.Wrapf(ErrUnsupported, "exploding dice with drop/keep %s", props.GroupModifiers)
+			}
+		}
+		return keepDistribution(die, kept), nil
+	}
+	return sum(die, props.Count), nil
+}
+
+// Die computes the distribution of a single die described by a properties
+// list, including the effects of its die-level modifiers.
+func (c *Calculator) Die(ctx context.Context, props *dice.RollerProperties) (*Distribution, error) {
+	f, ok := DieDistributions[props.Type]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnsupported, "die type %s", props.Type)
+	}
+	base, err := f(props)
+	if err != nil {
+		return nil, err
+	}
+
+	// rerolls apply before any explosion, whatever order they are written in,
+	// as a rerolled die's modifiers are applied again and each roll of an
+	// explosion is rerolled too
+	d := base
+	for _, mod := range props.DieModifiers {
+		if m, ok := mod.(*dice.RerollModifier); ok {
+			if d, err = reroll(ctx, props, d, m); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, mod := range props.DieModifiers {
+		switch m := mod.(type) {
+		case *dice.RerollModifier:
+			// applied above
+		case *dice.ExplodeModifier:
+			d, err = c.explode(d, base.Max(), m)
+		case *dice.CriticalSuccessModifier, *dice.CriticalFailureModifier:
+			// critical ranges do not affect a die's value
+		case *dice.ClampModifier:
+			// applied once the die's value is settled
+		default:
+			err = errors.Wrapf(ErrUnsupported, "modifier %s", mod)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, mod := range props.DieModifiers {
+		if m, ok := mod.(*dice.ClampModifier); ok {
+			d = d.Map(func(v float64) float64 {
+				switch {
+				case m.Method == dice.ClampMethodMin && v < float64(m.Target):
+					return float64(m.Target)
+				case m.Method == dice.ClampMethodMax && v > float64(m.Target):
+					return float64(m.Target)
+				}
+				return v
+			})
+		}
+	}
+	return d, nil
+}
+
+// reroll applies a reroll modifier to a die's distribution. Whether a face is
+// rerolled is determined by the modifier itself.
+func reroll(ctx context.Context, props *dice.RollerProperties, d *Distribution, m *dice.RerollModifier) (*Distribution, error) {
+	rerolled := make(map[float64]bool)
+	var pReroll float64
+	for _, o := range d.Outcomes() {
+		probe := &dice.Die{
+			Type:   props.Type,
+			Size:   props.Size,
+			Faces:  props.Faces,
+			Result: dice.NewResult(o.Value),
+		}
+		valid, err := m.Valid(ctx, probe)
+		if err != nil {
+			return nil, err
+		}
+		if !valid {
+			rerolled[o.Value] = true
+			pReroll += o.Probability
+		}
+	}
+
+	out := NewDistribution()
+	for _, o := range d.Outcomes() {
+		switch {
+		case m.Once && rerolled[o.Value]:
+			// replaced by the reroll's outcome
+			out.add(o.Value, pReroll*o.Probability)
+		case m.Once:
+			out.add(o.Value, o.Probability+pReroll*o.Probability)
+		case !rerolled[o.Value]:
+			// rerolled until valid, so conditional on validity
+			out.add(o.Value, o.Probability/(1-pReroll))
+		}
+	}
+	if out.Len() == 0 {
+		return nil, dice.ErrImpossibleRoll
+	}
+	return out, nil
+}
+
+// explode applies an explosion modifier to a die's distribution, following
+// explosions to the calculator's explosion depth, or once if the modifier only
+// explodes once. max is the die's highest face, which explodes by default. As
+// only the total matters, plain and compounding explosions are equivalent;
+// penetrating explosions subtract one from each additional roll.
+func (c *Calculator) explode(d *Distribution, max float64, m *dice.ExplodeModifier) (*Distribution, error) {
+	explodes := func(v float64) bool {
+		return matches(m.CompareTarget, max, v)
+	}
+	// a die that explodes on every face would never stop rolling
+	if All(d, explodes) {
+		return nil, dice.ErrImpossibleRoll
+	}
+
+	depth := c.ExplodeDepth
+	if m.Once {
+		depth = 1
+	}
+	var penalty float64
+	if m.Penetrate {
+		penalty = 1
+	}
+
+	// chain is the distribution of a die that may explode depth more times
+	chain := d
+	for i := 0; i < depth; i++ {
+		next := NewDistribution()
+		for _, o := range d.Outcomes() {
+			if !explodes(o.Value) {
+				next.add(o.Value, o.Probability)
+				continue
+			}
+			for _, e := range chain.Outcomes() {
+				next.add(o.Value+e.Value-penalty, o.Probability*e.Probability)
+			}
+		}
+		chain = next
+	}
+	return chain, nil
+}
+
+// matches returns whether a value matches a compare target, following the dice
+// package's semantics: < and > are inclusive of the target, and an empty
+// comparison is an equality. A target of 0 is replaced by def, if def is
+// nonzero.
+func matches(ct *dice.CompareTarget, def, v float64) bool {
+	target := float64(ct.Target)
+	if target == 0 && def != 0 {
+		target = def
+	}
+	switch ct.Compare {
+	case dice.EMPTY, dice.EQL:
+		return v == target
+	case dice.LSS, dice.LEQ:
+		return v <= target
+	case dice.GTR, dice.GEQ:
+		return v >= target
+	}
+	return false
+}
+
+// keptPositions returns which of count dice, in ascending order of value, are
+// kept after applying a group's modifiers.
+func keptPositions(count int, mods dice.ModifierList) ([]bool, error) {
+	kept := make([]bool, count)
+	for i := range kept {
+		kept[i] = true
+	}
+	drop := func(from, to int) {
+		for i := from; i < to && i < count; i++ {
+			if i >= 0 {
+				kept[i] = false
+			}
+		}
+	}
+	for _, mod := range mods {
+		switch m := mod.(type) {
+		case *dice.DropKeepModifier:
+			switch m.Method {
+			case dice.DropKeepMethodDrop, dice.DropKeepMethodDropLowest:
+				drop(0, m.Num)
+			case dice.DropKeepMethodKeep, dice.DropKeepMethodKeepHighest:
+				drop(0, count-m.Num)
+			case dice.DropKeepMethodDropHighest:
+				drop(count-m.Num, count)
+			case dice.DropKeepMethodKeepLowest:
+				drop(m.Num, count)
+			case dice.DropKeepMethodKeepMiddle:
+				low := (count - m.Num) / 2
+				drop(0, low)
+				drop(low+m.Num, count)
+			default:
+				return nil, errors.Wrapf(ErrUnsupported, "drop/keep method %q", m.Method)
+			}
+		case *dice.SortModifier, *dice.MatchModifier:
+			// sorting and matching do not affect a group's total
+		default:
+			return nil, errors.Wrapf(ErrUnsupported, "modifier %s", mod)
+		}
+	}
+	return kept, nil
+}
+
+// sum returns the distribution of the sum of n independent dice with the
+// same distribution, using exponentiation by squaring.
+func sum(die *Distribution, n int) *Distribution {
+	total := Constant(0)
+	for n > 0 {
+		if n&1 == 1 {
+			total = Add(total, die)
+		}
+		n >>= 1
+		if n > 0 {
+			die = Add(die, die)
+		}
+	}
+	return total
+}
+
+// keepDistribution returns the distribution of the sum of the kept dice of a
+// group of independent dice, where kept marks which positions of the group
+// sorted in ascending order are kept.
+//
+// Faces are assigned to the sorted positions in ascending order: for each face
+// the number of dice showing it is chosen, weighted by the multinomial
+// probability of that many dice showing the face.
+func keepDistribution(die *Distribution, kept []bool) *Distribution {
+	n := len(kept)
+	// states[m] maps the sum of kept dice to its weight, once m dice have
+	// been assigned faces
+	states := make([]map[float64]float64, n+1)
+	states[0] = map[float64]float64{0: 1}
+	for _, face := range die.Outcomes() {
+		next := make([]map[float64]float64, n+1)
+		for m, sums := range states {
+			if sums == nil {
+				continue
+			}
+			pj := 1.0
+			keptCount := 0
+			for j := 0; m+j <= n; j++ {
+				if j > 0 && kept[m+j-1] {
+					keptCount++
+				}
+				weight := binomial(n-m, j) * pj
+				if next[m+j] == nil {
+					next[m+j] = make(map[float64]float64)
+				}
+				for s, w := range sums {
+					next[m+j][s+float64(keptCount)*face.Value] += w * weight
+				}
+				pj *= face.Probability
+			}
+		}
+		states = next
+	}
+	return &Distribution{p: states[n]}
+}
+
+// binomial returns the binomial coefficient n choose k.
+func binomial(n, k int) float64 {
+	if k < 0 || k > n {
+		return 0
+	}
+	c := 1.0
+	for i := 1; i <= k; i++ {
+		c = c * float64(n-k+i) / float64(i)
+	}
+	return c
+}
@@ -0,0 +1,238 @@
+package probability
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Point is a possible result of an expression and its probability.
+type Point struct {
+	Value       float64 `json:"value"`
+	Probability float64 `json:"probability"`
+}
+
+// A Distribution is a discrete probability distribution of the outcomes of a
+// dice expression. The zero value is an empty distribution.
+type Distribution struct {
+	p map[float64]float64
+}
+
+// NewDistribution returns a distribution of the given outcomes. Probabilities
+// of duplicate values are summed.
+func NewDistribution(outcomes ...Point) *Distribution {
+	d := &Distribution{p: make(map[float64]float64, len(outcomes))}
+	for _, o := range outcomes {
+		d.add(o.Value, o.Probability)
+	}
+	return d
+}
+
+// Constant returns a distribution with a single certain outcome.
+func Constant(v float64) *Distribution {
+	return NewDistribution(Point{v, 1})
+}
+
+// Uniform returns a distribution where each of the values is equally likely.
+func Uniform(values ...float64) *Distribution {
+	d := &Distribution{p: make(map[float64]float64, len(values))}
+	for _, v := range values {
+		d.add(v, 1/float64(len(values)))
+	}
+	return d
+}
+
+func (d *Distribution) add(v, p float64) {
+	if d.p == nil {
+		d.p = make(map[float64]float64)
+	}
+	if p == 0 {
+		return
+	}
+	d.p[v] += p
+}
+
+// Outcomes returns the distribution's possible outcomes in ascending order of
+// value.
+func (d *Distribution) Outcomes() []Point {
+	outcomes := make([]Point, 0, len(d.p))
+	for v, p := range d.p {
+		outcomes = append(outcomes, Point{v, p})
+	}
+	sort.Slice(outcomes, func(i, j int) bool {
+		return outcomes[i].Value < outcomes[j].Value
+	})
+	return outcomes
+}
+
+// Len returns the number of distinct outcomes.
+func (d *Distribution) Len() int {
+	return len(d.p)
+}
+
+// Probability returns the probability of the value v.
+func (d *Distribution) Probability(v float64) float64 {
+	return d.p[v]
+}
+
+// AtLeast returns the probability of an outcome greater than or equal to n.
+func (d *Distribution) AtLeast(n float64) float64 {
+	var total float64
+	for v, p := range d.p {
+		if v >= n {
+			total += p
+		}
+	}
+	return total
+}
+
+// AtMost returns the probability of an outcome less than or equal to n.
+func (d *Distribution) AtMost(n float64) float64 {
+	var total float64
+	for v, p := range d.p {
+		if v <= n {
+			total += p
+		}
+	}
+	return total
+}
+
+// Mean returns the expected value of the distribution.
+func (d *Distribution) Mean() float64 {
+	var mean float64
+	for v, p := range d.p {
+		mean += v * p
+	}
+	return mean
+}
+
+// Variance returns the variance of the distribution.
+func (d *Distribution) Variance() float64 {
+	mean := d.Mean()
+	var variance float64
+	for v, p := range d.p {
+		variance += (v - mean) * (v - mean) * p
+	}
+	return variance
+}
+
+// StdDev returns the standard deviation of the distribution.
+func (d *Distribution) StdDev() float64 {
+	return math.Sqrt(d.Variance())
+}
+
+// Min returns the lowest possible outcome, or NaN if there are no outcomes.
+func (d *Distribution) Min() float64 {
+	outcomes := d.Outcomes()
+	if len(outcomes) == 0 {
+		return math.NaN()
+	}
+	return outcomes[0].Value
+}
+
+// Max returns the highest possible outcome, or NaN if there are no outcomes.
+func (d *Distribution) Max() float64 {
+	outcomes := d.Outcomes()
+	if len(outcomes) == 0 {
+		return math.NaN()
+	}
+	return outcomes[len(outcomes)-1].Value
+}
+
+// Mode returns the most probable outcome. If several outcomes are equally
+// probable the lowest is returned.
+func (d *Distribution) Mode() float64 {
+	mode, best := math.NaN(), -1.0
+	for _, o := range d.Outcomes() {
+		// allow for floating point error between equally likely outcomes
+		if o.Probability > best+1e-12 {
+			mode, best = o.Value, o.Probability
+		}
+	}
+	return mode
+}
+
+// Percentile returns the lowest outcome at or below which at least p percent
+// of the distribution lies. Percentile(50) is the median.
+func (d *Distribution) Percentile(p float64) float64 {
+	outcomes := d.Outcomes()
+	if len(outcomes) == 0 {
+		return math.NaN()
+	}
+	target := p / 100
+	var cumulative float64
+	for _, o := range outcomes {
+		cumulative += o.Probability
+		// allow for floating point error in the cumulative sum
+		if cumulative >= target-1e-12 {
+			return o.Value
+		}
+	}
+	return outcomes[len(outcomes)-1].Value
+}
+
+// All returns whether every possible outcome of a distribution matches a
+// predicate.
+func All(d *Distribution, f func(float64) bool) bool {
+	for v := range d.p {
+		if !f(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Map returns the distribution of f applied to each outcome.
+func (d *Distribution) Map(f func(float64) float64) *Distribution {
+	out := &Distribution{p: make(map[float64]float64, len(d.p))}
+	for v, p := range d.p {
+		out.add(f(v), p)
+	}
+	return out
+}
+
+// Combine returns the distribution of f applied to each pair of outcomes of
+// two independent distributions.
+func Combine(a, b *Distribution, f func(x, y float64) float64) *Distribution {
+	out := &Distribution{p: make(map[float64]float64, len(a.p)+len(b.p))}
+	for x, px := range a.p {
+		for y, py := range b.p {
+			out.add(f(x, y), px*py)
+		}
+	}
+	return out
+}
+
+// Mix returns the distribution of a with probability p, and of b otherwise.
+func Mix(p float64, a, b *Distribution) *Distribution {
+	out := &Distribution{p: make(map[float64]float64, len(a.p)+len(b.p))}
+	for x, px := range a.p {
+		out.add(x, p*px)
+	}
+	for y, py := range b.p {
+		out.add(y, (1-p)*py)
+	}
+	return out
+}
+
+// Add returns the distribution of the sum of two independent distributions.
+func Add(a, b *Distribution) *Distribution {
+	return Combine(a, b, func(x, y float64) float64 { return x + y })
+}
+
+func (d *Distribution) String() string {
+	outcomes := d.Outcomes()
+	parts := make([]string, len(outcomes))
+	for i, o := range outcomes {
+		parts[i] = fmt.Sprintf("%s: %.6g", strconv.FormatFloat(o.Value, 'f', -1, 64), o.Probability)
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// MarshalJSON encodes the distribution as a list of its outcomes.
+func (d *Distribution) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Outcomes())
+}
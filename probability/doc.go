@@ -0,0 +1,36 @@
+/*
+Package probability computes exact discrete probability distributions of dice
+expressions without rolling any dice.
+
+Expressions are parsed with the dice package's ParseExpression and the
+resulting syntax tree is walked: the distribution of each die is computed from
+its type and die-level modifiers, like rerolls and explosions, and dice groups
+are combined through convolution. Group drop/keep modifiers are modelled
+exactly by tracking which sorted positions of a group are kept.
+
+Every dice notation within an expression is independent of every other, so
+the distributions of arithmetic operations and function calls are computed by
+combining each pair (or tuple) of their operands' outcomes.
+
+Exploding dice have an unbounded number of outcomes, so explosions are only
+followed to a configurable depth. The probability of a die exploding beyond
+that depth is assigned to the outcome where the chain stops.
+
+# Simulation
+
+Some expressions cannot be analyzed exactly, and Calculate returns an error
+wrapping ErrUnsupported for them. These are expressions with:
+
+  - dice with symbol faces
+  - unique dice, like 3d6u
+  - exploding dice that are dropped or kept, or counted as successes
+  - dice dropped or kept by comparison and also by position
+  - success-counting pools with other group modifiers
+  - roll lists with more combinations of outcomes than can be enumerated
+
+They can be estimated with a Simulator instead. A Simulator evaluates an expression many times across
+several worker goroutines, each with an independent RNG source, and aggregates
+the results into an empirical distribution, along with a confidence interval
+of its mean.
+*/
+package probability
@@ -0,0 +1,253 @@
+package probability
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/travis-g/dice"
+)
+
+// approx reports whether two floats are equal within a small tolerance.
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		expression string
+		mean       float64
+		min, max   float64
+	}{
+		{"5", 5, 5, 5},
+		{"d6", 3.5, 1, 6},
+		{"2d6", 7, 2, 12},
+		{"d0", 0, 0, 0},
+		{"0d6", 0, 0, 0},
+		{"4dF", 0, -4, 4},
+		{"d20+5", 15.5, 6, 25},
+		{"-d4", -2.5, -4, -1},
+		{"2*d6", 7, 2, 12},
+		{"2d20kh1", 13.825, 1, 20},
+		{"2d20kl1", 7.175, 1, 20},
+		{"2d20dl1", 13.825, 1, 20},
+		{"4d6kh3", 15869.0 / 1296, 3, 18},
+		{"4d6dl1", 15869.0 / 1296, 3, 18},
+		{"4d6dl1dh1", 7, 2, 12},
+		{"3d6km1", 3.5, 1, 6},
+		{"8d10m", 44, 8, 80},
+		{"d{1,1,2,3,5,8}", 20.0 / 6, 1, 8},
+		{"4d{-1,0,0,1}", 0, -4, 4},
+		{"d{1,1,6}!o", 32.0 / 9, 1, 12},
+		{"d6{1,1,1,1,1,5}", 4.5, 1, 6},
+		{"d%", 50.5, 1, 100},
+		{"d66", 38.5, 11, 66},
+		{"d666", 388.5, 111, 666},
+		{"d4{0,1,0,3}", 3.5, 2, 4},
+		{"d4{0,1,0,3}!o", 6.125, 2, 8},
+		{"4d6d<3", 10, 0, 24},
+		{"2d6k>=5", 11.0 / 3, 0, 12},
+		{"3d6r1", 12, 6, 18},
+		{"d6ro1", 3.5 + 2.5/6, 1, 6},
+		{"d6!o", 3.5 + 3.5/6, 1, 12},
+		{"d6!!o", 3.5 + 3.5/6, 1, 12},
+		{"d6!po", 3.5 + 2.5/6, 1, 11},
+		{"2d6!!okh1", 7217.0 / 1296, 1, 12},
+		{"d20min10", 12.75, 10, 20},
+		{"2d20max15", 19.5, 2, 30},
+		{"d6min3max4", 3.5, 3, 4},
+		{"6d10>=8", 1.8, 0, 6},
+		{"8d6>4f1", 8.0 / 3, -8, 8},
+		{"4d6=6f<2", -2.0 / 3, -4, 4},
+		{"max(d6,d6)", 161.0 / 36, 1, 6},
+		{"floor(d6/2)", 1.5, 0, 3},
+		{"3x d6+1", 13.5, 6, 21},
+		{"{d6, d4}*2", 12, 4, 20},
+		{"{d20, d20}kh1", 13.825, 1, 20},
+		{"{1d20+5, 1d12+3}kh1", 259.0 / 16, 6, 25},
+		{"{d4, 2}dl1", 2.75, 2, 4},
+		{"{d20, d4}k>10", 8.25, 0, 20},
+		{"d20+5 >= 15", 0.55, 0, 1},
+		{"1d8[slashing] + 2d6[fire]", 11.5, 3, 20},
+		{"d20 >= 15 ? 2d6 : 0", 2.1, 0, 12},
+		{"d6 = 6 ? 2d6 : d6 != 1 ? d6 : 0", 7.0/6 + 3.5*25/36, 0, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			d, err := Calculate(context.Background(), tt.expression)
+			if err != nil {
+				t.Fatalf("Calculate() error = %v", err)
+			}
+			if !approx(d.Mean(), tt.mean) {
+				t.Errorf("Mean() = %v, want %v", d.Mean(), tt.mean)
+			}
+			if d.Min() != tt.min || d.Max() != tt.max {
+				t.Errorf("range = [%v, %v], want [%v, %v]", d.Min(), d.Max(), tt.min, tt.max)
+			}
+			var total float64
+			for _, o := range d.Outcomes() {
+				total += o.Probability
+			}
+			if !approx(total, 1) {
+				t.Errorf("probabilities sum to %v, want 1", total)
+			}
+		})
+	}
+}
+
+func TestCalculate_Penetrate(t *testing.T) {
+	c := &Calculator{ExplodeDepth: 50}
+	d, err := c.Expression(context.Background(), "d6!p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// m = 3.5 + (m-1)/6
+	if !approx(d.Mean(), 4) {
+		t.Errorf("Mean() = %v, want 4", d.Mean())
+	}
+	// a 6 penetrates to at least 6+1-1
+	if p := d.Probability(6); p != 1.0/36 {
+		t.Errorf("Probability(6) = %v, want %v", p, 1.0/36)
+	}
+}
+
+func TestCalculate_Explode(t *testing.T) {
+	c := &Calculator{ExplodeDepth: 2}
+	d, err := c.Expression(context.Background(), "d6!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a 6 always explodes, so it can never be the final total
+	if p := d.Probability(6); p != 0 {
+		t.Errorf("Probability(6) = %v, want 0", p)
+	}
+	if p, want := d.Probability(7), 1.0/36; !approx(p, want) {
+		t.Errorf("Probability(7) = %v, want %v", p, want)
+	}
+	if d.Max() != 18 {
+		t.Errorf("Max() = %v, want 18", d.Max())
+	}
+}
+
+func TestCalculate_Variables(t *testing.T) {
+	ctx := context.WithValue(context.Background(), dice.CtxKeyParameters,
+		map[string]interface{}{"level": 2, "str_mod": 3})
+	d, err := Calculate(ctx, "@level d6 + @str_mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !approx(d.Mean(), 10) || d.Min() != 5 || d.Max() != 15 {
+		t.Errorf("got mean %v over [%v, %v], want 10 over [5, 15]", d.Mean(), d.Min(), d.Max())
+	}
+	if _, err := Calculate(context.Background(), "d20 + @str_mod"); err == nil {
+		t.Errorf("Calculate() of an unbound variable wanted error")
+	}
+}
+
+func TestCalculate_Errors(t *testing.T) {
+	tests := []string{
+		"d6r<6",
+		"4d6!kh3",
+		"4d6d<3kh2",
+		"3d6u",
+		"{d6, d4}d<2kh1",
+		"d{1,2,star}",
+		"unknown(d6)",
+		"d20+",
+	}
+	for _, expression := range tests {
+		if _, err := Calculate(context.Background(), expression); err == nil {
+			t.Errorf("Calculate(%q) wanted error", expression)
+		}
+	}
+}
+
+func TestDistribution_Stats(t *testing.T) {
+	d, err := Calculate(context.Background(), "2d6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, want := d.Probability(7), 1.0/6; !approx(p, want) {
+		t.Errorf("Probability(7) = %v, want %v", p, want)
+	}
+	if mode := d.Mode(); mode != 7 {
+		t.Errorf("Mode() = %v, want 7", mode)
+	}
+	if median := d.Percentile(50); median != 7 {
+		t.Errorf("Percentile(50) = %v, want 7", median)
+	}
+	if v, want := d.Variance(), 35.0/6; !approx(v, want) {
+		t.Errorf("Variance() = %v, want %v", v, want)
+	}
+	if p, want := d.AtLeast(10), 6.0/36; !approx(p, want) {
+		t.Errorf("AtLeast(10) = %v, want %v", p, want)
+	}
+	if p, want := d.AtMost(3), 3.0/36; !approx(p, want) {
+		t.Errorf("AtMost(3) = %v, want %v", p, want)
+	}
+}
+
+func TestSimulator_Run(t *testing.T) {
+	s := &Simulator{Trials: 2000, Workers: 4}
+	sim, err := s.Run(context.Background(), "2d6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim.Trials != 2000 {
+		t.Errorf("Trials = %v, want 2000", sim.Trials)
+	}
+	if sim.Distribution.Min() < 2 || sim.Distribution.Max() > 12 {
+		t.Errorf("range = [%v, %v], want within [2, 12]", sim.Distribution.Min(), sim.Distribution.Max())
+	}
+	// a 99.9% interval, to keep the test from being flaky
+	if math.Abs(sim.Mean-7) > 3.3*sim.StdErr {
+		t.Errorf("Mean = %v ± %v, want 7", sim.Mean, sim.StdErr)
+	}
+	if len(sim.Convergence) != 10 {
+		t.Errorf("len(Convergence) = %v, want 10", len(sim.Convergence))
+	}
+}
+
+func TestSimulator_Constant(t *testing.T) {
+	s := &Simulator{Trials: 100, Workers: 3}
+	sim, err := s.Run(context.Background(), "d1+1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim.Mean != 2 || sim.StdDev != 0 || sim.Distribution.Probability(2) != 1 {
+		t.Errorf("got %+v, want a certain 2", sim)
+	}
+}
+
+func TestSimulator_Errors(t *testing.T) {
+	s := &Simulator{Trials: 10}
+	for _, expression := range []string{"d20+", "unknown(d6)"} {
+		if _, err := s.Run(context.Background(), expression); err == nil {
+			t.Errorf("Run(%q) wanted error", expression)
+		}
+	}
+}
+
+func TestSimulator_MatchesCalculate(t *testing.T) {
+	// rerolls apply to each roll of a compounding or penetrating chain, and
+	// before explosions whatever order they are written in
+	s := &Simulator{Trials: 20000, Workers: 4, Source: func(worker int) *rand.Rand {
+		return rand.New(rand.NewSource(int64(worker)))
+	}}
+	for _, expression := range []string{
+		"d6r1!!", "d6r1!p", "d6ro1!!", "d6r<2!p", "d6r1!", "3d6!r1", "2d6!!r1", "d6!pr1", "d6!ro1",
+	} {
+		dist, err := Calculate(context.Background(), expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sim, err := s.Run(context.Background(), expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(sim.Mean-dist.Mean()) > 3.3*sim.StdErr {
+			t.Errorf("%s: simulated mean = %v ± %v, calculated %v", expression, sim.Mean, sim.StdErr, dist.Mean())
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package probability
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+
+	"github.com/travis-g/dice"
+	dicemath "github.com/travis-g/dice/math"
+)
+
+// DefaultTrials is the number of trials a Simulator runs if unset.
+var DefaultTrials = 10000
+
+// simulationBatch is the number of trial results workers send to be
+// aggregated at once.
+const simulationBatch = 256
+
+// A Simulator estimates the distribution of an expression by evaluating it
+// many times. Simulation supports any expression that can be evaluated,
+// including those that cannot be analyzed exactly, like expressions with
+// unbounded explosions.
+type Simulator struct {
+	// Trials is the number of times to evaluate the expression.
+	Trials int
+
+	// Workers is the number of goroutines evaluating the expression in
+	// parallel. It defaults to the number of CPUs.
+	Workers int
+
+	// Checkpoints is the number of evenly spaced points during the simulation
+	// at which to record convergence information. It defaults to 10.
+	Checkpoints int
+
+	// Source returns the RNG source for a worker, which is set within the
+	// context of the worker's trials. Each worker should have an independent
+	// source. By default each worker uses a math/rand source seeded from the
+	// system's CSPRNG.
+	Source func(worker int) *rand.Rand
+}
+
+// cryptoSeededSource returns a new math/rand source seeded from the system's
+// CSPRNG.
+func cryptoSeededSource(int) *rand.Rand {
+	seed, err := dice.CryptoInt64()
+	if err != nil {
+		panic(err)
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// A Checkpoint records the state of a simulation's estimate of the mean after
+// a number of trials.
+type Checkpoint struct {
+	Trials int     `json:"trials"`
+	Mean   float64 `json:"mean"`
+	StdErr float64 `json:"stderr"`
+}
+
+// A Simulation is the aggregated result of a Simulator's trials.
+type Simulation struct {
+	Expression string `json:"expression"`
+	Trials     int    `json:"trials"`
+
+	// Distribution is the empirical distribution of the trials' results.
+	Distribution *Distribution `json:"distribution"`
+
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+
+	// StdErr is the standard error of the estimated mean.
+	StdErr float64 `json:"stderr"`
+
+	// Interval is the 95% confidence interval of the mean.
+	Interval [2]float64 `json:"interval"`
+
+	// Convergence is the estimated mean recorded at checkpoints throughout
+	// the simulation.
+	Convergence []Checkpoint `json:"convergence"`
+}
+
+// z95 is the standard score for a two-sided 95% confidence interval.
+const z95 = 1.959963984540054
+
+// Simulate estimates the distribution of an expression by evaluating it
+// DefaultTrials times.
+func Simulate(ctx context.Context, expression string) (*Simulation, error) {
+	s := &Simulator{Trials: DefaultTrials}
+	return s.Run(ctx, expression)
+}
+
+// Run evaluates the expression the Simulator's number of trials and
+// aggregates the results. Each trial is evaluated with its own roll counter,
+// so roll limits apply per trial. The first error encountered by any
+// trial stops the simulation and is returned.
+func (s *Simulator) Run(ctx context.Context, expression string) (*Simulation, error) {
+	// parse once up front so that parse errors are reported immediately
+	if _, err := dice.ParseExpression(ctx, expression); err != nil {
+		return nil, err
+	}
+
+	trials := s.Trials
+	if trials <= 0 {
+		trials = DefaultTrials
+	}
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > trials {
+		workers = trials
+	}
+	checkpoints := s.Checkpoints
+	if checkpoints <= 0 {
+		checkpoints = 10
+	}
+	source := s.Source
+	if source == nil {
+		source = cryptoSeededSource
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		results  = make(chan []float64, workers)
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for w := 0; w < workers; w++ {
+		// spread the remainder of trials over the first workers
+		n := trials / workers
+		if w < trials%workers {
+			n++
+		}
+		wg.Add(1)
+		go func(ctx context.Context, n int) {
+			defer wg.Done()
+			// each worker parses its own syntax tree, as modifiers are not
+			// safe for concurrent use
+			node, err := dice.ParseExpression(ctx, expression)
+			if err != nil {
+				fail(err)
+				return
+			}
+			batch := make([]float64, 0, simulationBatch)
+			for i := 0; i < n; i++ {
+				// each trial gets its own roll counter
+				trialCtx := context.WithValue(ctx, dice.CtxKeyTotalRolls, new(uint64))
+				de, err := dicemath.Evaluate(trialCtx, expression, node)
+				if err != nil {
+					fail(err)
+					return
+				}
+				batch = append(batch, de.Result)
+				if len(batch) == simulationBatch || i == n-1 {
+					select {
+					case results <- batch:
+					case <-ctx.Done():
+						return
+					}
+					batch = make([]float64, 0, simulationBatch)
+				}
+			}
+		}(context.WithValue(ctx, dice.CtxKeySource, source(w)), n)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	sim := &Simulation{
+		Expression:   expression,
+		Distribution: NewDistribution(),
+		Convergence:  make([]Checkpoint, 0, checkpoints),
+	}
+	var (
+		counts     = make(map[float64]int)
+		sum, sumSq float64
+		next       = 1
+	)
+	for batch := range results {
+		for _, v := range batch {
+			counts[v]++
+			sum += v
+			sumSq += v * v
+			sim.Trials++
+			if sim.Trials >= next*trials/checkpoints {
+				mean, _, stdErr := moments(sim.Trials, sum, sumSq)
+				sim.Convergence = append(sim.Convergence, Checkpoint{
+					Trials: sim.Trials,
+					Mean:   mean,
+					StdErr: stdErr,
+				})
+				next++
+			}
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil && sim.Trials < trials {
+		return nil, err
+	}
+
+	for v, count := range counts {
+		sim.Distribution.add(v, float64(count)/float64(sim.Trials))
+	}
+	sim.Mean, sim.StdDev, sim.StdErr = moments(sim.Trials, sum, sumSq)
+	sim.Interval = [2]float64{sim.Mean - z95*sim.StdErr, sim.Mean + z95*sim.StdErr}
+	return sim, nil
+}
+
+// moments returns the mean, sample standard deviation, and standard error of
+// the mean of n values given their sum and sum of squares.
+func moments(n int, sum, sumSq float64) (mean, stdDev, stdErr float64) {
+	if n == 0 {
+		return math.NaN(), math.NaN(), math.NaN()
+	}
+	mean = sum / float64(n)
+	if n == 1 {
+		return mean, 0, 0
+	}
+	variance := (sumSq - sum*sum/float64(n)) / float64(n-1)
+	if variance < 0 {
+		// guard against floating point error
+		variance = 0
+	}
+	stdDev = math.Sqrt(variance)
+	stdErr = stdDev / math.Sqrt(float64(n))
+	return
+}
@@ -15,8 +15,56 @@ type Result struct {
 	Dropped     bool    `json:"dropped,omitempty"`
 	CritSuccess bool    `json:"crit,omitempty"`
 	CritFailure bool    `json:"fumble,omitempty"`
+
+	// Symbol is the symbol rolled, if the die rolled a symbol face.
+	Symbol string `json:"symbol,omitempty"`
+
+	// Outcome is whether the result counts as a success or failure, if the
+	// die was rolled as part of a success-counting pool.
+	Outcome PoolOutcome `json:"outcome,omitempty"`
+
+	// Nonce is the nonce of the FairSource roll that produced the Value, if
+	// the die was rolled with a FairSource.
+	Nonce *uint64 `json:"nonce,omitempty"`
+
+	// History is the ordered list of the die's prior values that were replaced
+	// to reach this Result, oldest first.
+	History []PriorResult `json:"history,omitempty"`
+}
+
+// A PriorResult is a value a die rolled that was later replaced, along with the
+// kind of replacement and the notation of the modifier that replaced it.
+type PriorResult struct {
+	Value  float64     `json:"value"`
+	Kind   Replacement `json:"kind,omitempty"`
+	Reason string      `json:"reason,omitempty"`
+	Nonce  *uint64     `json:"nonce,omitempty"`
 }
 
+// A Replacement is how a die's value was replaced by a modifier.
+type Replacement string
+
+// Value replacements.
+const (
+	ReplacementNone      Replacement = ""
+	ReplacementReroll    Replacement = "reroll"    // rolled again
+	ReplacementCompound  Replacement = "compound"  // a roll added to the value
+	ReplacementPenetrate Replacement = "penetrate" // a roll less 1 added to the value
+	ReplacementMin       Replacement = "min"       // raised to a minimum
+	ReplacementMax       Replacement = "max"       // lowered to a maximum
+)
+
+// A PoolOutcome is how a die's result counts towards a success-counting pool.
+type PoolOutcome string
+
+// Pool outcomes.
+const (
+	PoolOutcomeNone    PoolOutcome = ""
+	PoolOutcomeSuccess PoolOutcome = "success"
+	PoolOutcomeFailure PoolOutcome = "failure"
+	PoolOutcomeNeutral PoolOutcome = "neutral"
+)
+
 // NewResult returns a new un-dropped, non-critical Result.
 func NewResult(result float64) *Result {
 	return &Result{
@@ -5,6 +5,8 @@ import (
 	"context"
 	"fmt"
 	"strings"
+
+	"github.com/pkg/errors"
 )
 
 // Roller must be implemented for an object to be considered rollable.
@@ -66,6 +68,12 @@ type RollerProperties struct {
 	Result *Result `json:"result,omitempty" mapstructure:"result"`
 	Count  int     `json:"count,omitempty" mapstructure:"count"`
 
+	// Faces are the faces of a custom die.
+	Faces []Face `json:"faces,omitempty" mapstructure:"faces"`
+
+	// Weights are the relative weights of each of a weighted die's faces.
+	Weights []float64 `json:"weights,omitempty" mapstructure:"weights"`
+
 	// Modifiers for the dice or parent set
 	DieModifiers   ModifierList `json:"die_modifiers,omitempty" mapstructure:"die_modifiers"`
 	GroupModifiers ModifierList `json:"group_modifiers,omitempty" mapstructure:"group_modifiers"`
@@ -82,6 +90,10 @@ type RollerFactory func(*RollerProperties, Roller) (Roller, error)
 var RollerFactoryMap = map[DieType]RollerFactory{
 	TypePolyhedron: NewDie,
 	TypeFudge:      NewDie,
+	TypeCustom:     NewCustomDie,
+	TypeWeighted:   NewWeightedDie,
+	TypePercentile: NewPercentileDie,
+	TypeDigits:     NewDigitDie,
 }
 
 // NewRollerWithParent creates a new Die to roll off of a supplied property set. The
@@ -118,9 +130,13 @@ func MustNewRoller(props *RollerProperties) Roller {
 type DiceRollSet struct {
 }
 
-// A Group is a slice of rollables.
+// A Group is a slice of rollables. As adding a Roller to a Group modifies it,
+// and rolling a Group must see the dice added by explosions, a *Group
+// implements Roller but a Group value does not.
 type Group []Roller
 
+var _ Roller = (*Group)(nil)
+
 // Total implements the Total method and sums a dice group's totals, excluding
 // values of dropped dice.
 func (g Group) Total(ctx context.Context) (total float64, err error) {
@@ -149,7 +165,8 @@ func (g Group) String() string {
 		temp = []string{"0"}
 	}
 	t, _ := g.Total(context.TODO())
-	return fmt.Sprintf("%s => %.0f", expression(strings.Join(temp, "+")), t)
+	// join directly, as expression would trim the brackets of die histories
+	return fmt.Sprintf("%s => %.0f", strings.Replace(strings.Join(temp, "+"), "+-", "-", -1), t)
 }
 
 // Drop is (presently) a noop on the group.
@@ -171,16 +188,17 @@ func (g Group) Copy() []Roller {
 
 // FullRoll implements the Roller interface's FullRoll method by rolling each
 // object/Roller within the group.
-func (g Group) FullRoll(ctx context.Context) (err error) {
+func (g *Group) FullRoll(ctx context.Context) (err error) {
 	// ensure context has roll counter
 	if _, ok := ctx.Value(CtxKeyTotalRolls).(*uint64); !ok {
 		ctx = context.WithValue(ctx, CtxKeyTotalRolls, new(uint64))
 	}
 
-	// as Groups can extend if exploded, iterate by index until the end
+	// as Groups can extend if exploded, iterate by index until the end, which
+	// must be read through the pointer to see the dice added
 	i := 0
-	for i < len(g) {
-		err = g[i].FullRoll(ctx)
+	for i < len(*g) {
+		err = (*g)[i].FullRoll(ctx)
 		if err != nil {
 			break
 		}
@@ -213,7 +231,8 @@ func (g Group) Reroll(ctx context.Context) (err error) {
 
 // Expression returns an expression to represent the group's total. Dice in the
 // group that are unrolled are replaced with their roll notations and dropped
-// dice results are omitted.
+// dice results are omitted. Dice with replaced values are rendered with their
+// history, like [1→4].
 func (g Group) Expression() string {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -243,8 +262,10 @@ func (g Group) SetParent(Roller) {
 	panic("impossible action")
 }
 
-func (g Group) Add(r Roller) {
-	g = append(g, r)
+// Add appends a Roller to the Group. Add has a pointer receiver so that the
+// Roller is kept, so only a *Group satisfies the Roller interface.
+func (g *Group) Add(r Roller) {
+	*g = append(*g, r)
 }
 
 func (g Group) ToGraphviz() string {
@@ -289,7 +310,29 @@ func (g Group) Swap(i, j int) {
 type RollerGroup struct {
 	Group     `json:"group" mapstructure:"group"`
 	Modifiers ModifierList `json:"modifiers,omitempty" mapstructure:"modifiers"`
-	parent    Roller
+
+	// Sets and Pairs are the matching faces found by a MatchModifier.
+	Sets  []Set `json:"sets,omitempty" mapstructure:"sets"`
+	Pairs int   `json:"pairs,omitempty" mapstructure:"pairs"`
+
+	parent Roller
+}
+
+// A Set is a group of dice that rolled the same face, in One Roll Engine
+// terms: its Width is the number of matching dice, and its Height is the face
+// they matched. Sets of a custom die's symbol faces record the Symbol.
+type Set struct {
+	Width  int     `json:"width"`
+	Height float64 `json:"height"`
+	Symbol string  `json:"symbol,omitempty"`
+}
+
+// String returns the set as width×height, like 3×7.
+func (s Set) String() string {
+	if s.Symbol != "" {
+		return fmt.Sprintf("%d×%s", s.Width, s.Symbol)
+	}
+	return fmt.Sprintf("%d×%.0f", s.Width, s.Height)
 }
 
 // NewRollerGroup creates a new dice group with the count provided by the
@@ -348,6 +391,106 @@ func (d *RollerGroup) FullRoll(ctx context.Context) error {
 	return nil
 }
 
+// IsPool returns whether the group is a success-counting pool, meaning it has a
+// SuccessModifier or FailureModifier.
+func (d *RollerGroup) IsPool() bool {
+	for _, mod := range d.Modifiers {
+		switch mod.(type) {
+		case *SuccessModifier, *FailureModifier:
+			return true
+		}
+	}
+	return false
+}
+
+// Total returns the group's total. The total of a success-counting pool is its
+// number of successes less its number of failures, excluding dropped dice.
+func (d *RollerGroup) Total(ctx context.Context) (float64, error) {
+	if !d.IsPool() {
+		return d.Group.Total(ctx)
+	}
+	var total float64
+	for _, r := range d.Group {
+		die, ok := r.(*Die)
+		if !ok {
+			return total, errors.New("roller not a die")
+		}
+		if die.Result == nil {
+			return total, ErrUnrolled
+		}
+		if die.IsDropped(ctx) {
+			continue
+		}
+		switch die.Outcome {
+		case PoolOutcomeSuccess:
+			total++
+		case PoolOutcomeFailure:
+			total--
+		}
+	}
+	return total, nil
+}
+
+// Expression returns an expression to represent the group's total. The dice of
+// a success-counting pool are listed with successes marked ✓ and failures
+// marked ✗, as their values are not summed.
+func (d *RollerGroup) Expression() string {
+	if !d.IsPool() {
+		return d.Group.Expression()
+	}
+	ctx := context.Background()
+	dice := make([]string, 0, len(d.Group))
+	for _, r := range d.Group {
+		if r.IsDropped(ctx) {
+			continue
+		}
+		s := r.String()
+		if die, ok := r.(*Die); ok && die.Result != nil {
+			switch die.Outcome {
+			case PoolOutcomeSuccess:
+				s += "✓"
+			case PoolOutcomeFailure:
+				s += "✗"
+			}
+		}
+		dice = append(dice, s)
+	}
+	return strings.Join(dice, ", ")
+}
+
+// String returns the group's expression and total, followed by its sets if
+// the group was matched, like "7+7+7+4+4+1 => 30 (3×7, 2×4)".
+func (d *RollerGroup) String() string {
+	var s string
+	if d.IsPool() {
+		total, _ := d.Total(context.TODO())
+		s = fmt.Sprintf("%s => %.0f", d.Expression(), total)
+	} else {
+		s = d.Group.String()
+	}
+	if !d.isMatched() {
+		return s
+	}
+	if len(d.Sets) == 0 {
+		return s + " (no sets)"
+	}
+	sets := make([]string, len(d.Sets))
+	for i, set := range d.Sets {
+		sets[i] = set.String()
+	}
+	return fmt.Sprintf("%s (%s)", s, strings.Join(sets, ", "))
+}
+
+// isMatched returns whether the group has a MatchModifier.
+func (d *RollerGroup) isMatched() bool {
+	for _, mod := range d.Modifiers {
+		if _, ok := mod.(*MatchModifier); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // Reroll re-rolls each die within the dice group.
 func (d *RollerGroup) Reroll(ctx context.Context) error {
 	if err := d.Group.Reroll(ctx); err != nil {
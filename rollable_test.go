@@ -9,8 +9,6 @@ import (
 
 var _ Roller = (*RollerGroup)(nil)
 
-var _ Roller = (*Group)(nil)
-
 // ensure a Group can be sorted.
 var _ sort.Interface = (*Group)(nil)
 
@@ -179,3 +177,74 @@ func TestRollerGroup_FullRoll(t *testing.T) {
 		})
 	}
 }
+
+func TestRollerGroup_Pool(t *testing.T) {
+	tests := []struct {
+		notation   string
+		total      float64
+		expression string
+		outcomes   []PoolOutcome
+	}{
+		{"3d1>=1", 3, "1✓, 1✓, 1✓", []PoolOutcome{PoolOutcomeSuccess, PoolOutcomeSuccess, PoolOutcomeSuccess}},
+		{"2d1>2", 0, "1, 1", []PoolOutcome{PoolOutcomeNeutral, PoolOutcomeNeutral}},
+		{"2d1>=1f1", -2, "1✗, 1✗", []PoolOutcome{PoolOutcomeFailure, PoolOutcomeFailure}},
+		{"2d1f1>=1", -2, "1✗, 1✗", []PoolOutcome{PoolOutcomeFailure, PoolOutcomeFailure}},
+		{"2d1f<0", 0, "1, 1", []PoolOutcome{PoolOutcomeNeutral, PoolOutcomeNeutral}},
+		{"3d1>=1kh2", 2, "1✓, 1✓", []PoolOutcome{PoolOutcomeSuccess, PoolOutcomeSuccess, PoolOutcomeSuccess}},
+		{"3d1", 3, "1+1+1", []PoolOutcome{PoolOutcomeNone, PoolOutcomeNone, PoolOutcomeNone}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.notation, func(t *testing.T) {
+			ctx := context.Background()
+			props, err := ParseNotation(ctx, tt.notation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			group := MustNewRollerGroup(&props)
+			if err := group.FullRoll(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if total, _ := group.Total(ctx); total != tt.total {
+				t.Errorf("Total() = %v, want %v", total, tt.total)
+			}
+			if got := group.Expression(); got != tt.expression {
+				t.Errorf("Expression() = %q, want %q", got, tt.expression)
+			}
+			for i, r := range group.Group {
+				if got := r.(*Die).Outcome; got != tt.outcomes[i] {
+					t.Errorf("die %d outcome = %q, want %q", i, got, tt.outcomes[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewRollerGroup_Modifiers(t *testing.T) {
+	ctx := context.Background()
+	reroll := &RerollModifier{CompareTarget: &CompareTarget{EMPTY, 1}}
+	props := &RollerProperties{
+		Count:        3,
+		Size:         6,
+		DieModifiers: ModifierList{reroll},
+	}
+	group := MustNewRollerGroup(props)
+	if err := group.FullRoll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// applying a modifier must not change it
+	if reroll.Compare != EMPTY {
+		t.Errorf("Compare = %v after rolling, want %v", reroll.Compare, EMPTY)
+	}
+	seen := map[Modifier]bool{reroll: true}
+	for i, r := range group.Group {
+		die := r.(*Die)
+		if die.Result.Value == 1 {
+			t.Errorf("die %d was not rerolled: %v", i, die.Result.Value)
+		}
+		mod := die.Modifiers[0]
+		if seen[mod] {
+			t.Errorf("die %d shares modifier %p", i, mod)
+		}
+		seen[mod] = true
+	}
+}